@@ -1,45 +1,137 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/redis/go-redis/v9"
-	"go.mattglei.ch/lcp/internal/apis/applemusic"
-	"go.mattglei.ch/lcp/internal/apis/github"
-	"go.mattglei.ch/lcp/internal/apis/steam"
-	"go.mattglei.ch/lcp/internal/apis/workouts"
+	"go.mattglei.ch/lcp/internal/auth"
+	"go.mattglei.ch/lcp/internal/cache"
+	"go.mattglei.ch/lcp/internal/cluster"
+	"go.mattglei.ch/lcp/internal/config"
+	"go.mattglei.ch/lcp/internal/images"
+	"go.mattglei.ch/lcp/internal/metrics"
+	"go.mattglei.ch/lcp/internal/ratelimit"
+	"go.mattglei.ch/lcp/internal/replay"
 	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/lcp/internal/tracing"
 	"go.mattglei.ch/timber"
 )
 
+// How long each step of shutting down is given after a SIGTERM. Each step has its own timeout so
+// that one running long doesn't leave the next without time, and together they're kept under the
+// 30 second grace period given by docker and kubernetes.
+const (
+	// serverShutdownTimeout is for requests that are in progress.
+	serverShutdownTimeout = 5 * time.Second
+	// cacheShutdownTimeout is for cache updates that are in progress, such as strava map uploads.
+	cacheShutdownTimeout = 18 * time.Second
+	// tracingShutdownTimeout is for flushing traces.
+	tracingShutdownTimeout = 2 * time.Second
+)
+
 func main() {
 	setupLogger()
 	timber.Info("booted")
 
 	secrets.Load()
+	err := config.Load()
+	if err != nil {
+		timber.Fatal(err, "failed to load config")
+	}
+	err = auth.Load()
+	if err != nil {
+		timber.Fatal(err, "failed to load api tokens")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go config.Watch(ctx)
+	go secrets.Watch(ctx)
+	go auth.Watch(ctx)
+
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		timber.Fatal(err, "failed to setup tracing")
+	}
+	err = replay.Setup()
+	if err != nil {
+		timber.Fatal(err, "failed to setup http replay")
+	}
 
 	var (
-		client = http.Client{Timeout: 20 * time.Second}
-		mux    = http.NewServeMux()
-		rdb    = redis.NewClient(&redis.Options{
-			Addr:     secrets.ENV.RedisAddress,
-			Password: secrets.ENV.RedisPassword,
+		client = http.Client{
+			Timeout:   20 * time.Second,
+			Transport: tracing.Transport(replay.Transport(http.DefaultTransport)),
+		}
+		mux = http.NewServeMux()
+		rdb = redis.NewClient(&redis.Options{
+			Addr:     secrets.Get().RedisAddress,
+			Password: secrets.Get().RedisPassword,
 			DB:       0,
 		})
 	)
+	tracing.InstrumentRedis(rdb)
+
+	err = cache.SetupStorage(rdb)
+	if err != nil {
+		timber.Fatal(err, "failed to setup cache storage")
+	}
+	err = ratelimit.Setup(rdb)
+	if err != nil {
+		timber.Fatal(err, "failed to setup rate limits")
+	}
+	err = cluster.Setup(ctx, rdb)
+	if err != nil {
+		timber.Fatal(err, "failed to setup cluster")
+	}
 
 	mux.HandleFunc("/", rootRedirect)
-	github.Setup(mux)
-	workouts.Setup(mux, &client, rdb)
-	steam.Setup(mux, &client, rdb)
-	applemusic.Setup(mux, &client, rdb)
+	mux.HandleFunc("GET /healthz", cache.ServeHealth)
+	mux.HandleFunc("GET /readyz", cache.ServeReady)
+	mux.HandleFunc("GET /status", cache.ServeStatus)
+	mux.HandleFunc("GET /metrics", serveMetrics)
+	mux.HandleFunc("POST /admin/blurhash/purge", images.PurgeRoute(rdb))
+	cache.RegisterAdminRoutes(mux)
+	setupProviders(ctx, mux, &client, rdb)
+
+	server := http.Server{Addr: ":8000", Handler: logRequests(mux)}
+	server.RegisterOnShutdown(cache.CloseStreams)
+	go func() {
+		timber.Info("starting server")
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			timber.Fatal(err, "failed to start router")
+		}
+	}()
+
+	<-ctx.Done()
+	// a second signal kills the process immediately
+	stop()
+	timber.Info("shutting down")
 
-	timber.Info("starting server")
-	err := http.ListenAndServe(":8000", mux)
+	serverCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(serverCtx)
 	if err != nil {
-		timber.Fatal(err, "failed to start router")
+		timber.Error(err, "failed to gracefully shutdown server")
 	}
+
+	cacheCtx, cancel := context.WithTimeout(context.Background(), cacheShutdownTimeout)
+	defer cancel()
+	cache.Shutdown(cacheCtx)
+
+	tracingCtx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	err = shutdownTracing(tracingCtx)
+	if err != nil {
+		timber.Error(err, "failed to flush traces")
+	}
+	timber.Done("shutdown complete")
 }
 
 func setupLogger() {
@@ -51,6 +143,13 @@ func setupLogger() {
 	timber.TimeFormat("01/02 03:04:05 PM MST")
 }
 
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAuthorized(w, r, auth.ScopeMetrics) {
+		return
+	}
+	metrics.Handler.ServeHTTP(w, r)
+}
+
 func rootRedirect(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "https://mattglei.ch/writing/lcp", http.StatusPermanentRedirect)
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mattglei.ch/lcp/internal/auth"
+	"go.mattglei.ch/lcp/internal/metrics"
+	"go.mattglei.ch/timber"
+)
+
+// logRequests logs every request along with the name of the token that it was authorized with.
+// Health checks are skipped as they are sent every few seconds.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		r, tokenName := auth.WithTokenName(r)
+		rec := metrics.NewRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		name := tokenName()
+		if name == "" {
+			name = "anonymous"
+		}
+		timber.Info(fmt.Sprintf(
+			"%s %s %d %s (%s)",
+			r.Method,
+			r.URL.Path,
+			rec.Status,
+			time.Since(start).Round(time.Millisecond),
+			name,
+		))
+	})
+}
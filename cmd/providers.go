@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.mattglei.ch/lcp/internal/cache"
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/timber"
+
+	// providers register themselves with the cache package when imported
+	_ "go.mattglei.ch/lcp/internal/apis/applemusic"
+	_ "go.mattglei.ch/lcp/internal/apis/github"
+	_ "go.mattglei.ch/lcp/internal/apis/steam"
+	_ "go.mattglei.ch/lcp/internal/apis/workouts"
+)
+
+// setupProviders starts every registered provider that is enabled, logging a report of which
+// providers are active and why the others aren't. A provider that fails to setup is disabled rather
+// than stopping the server.
+func setupProviders(
+	ctx context.Context,
+	mux *http.ServeMux,
+	client *http.Client,
+	rdb *redis.Client,
+) {
+	var (
+		providers = cache.Providers()
+		selected  = secrets.Get().Providers
+		deps      = cache.Deps{Mux: mux, Client: client, Redis: rdb}
+	)
+	for _, name := range selected {
+		known := slices.ContainsFunc(providers, func(p cache.Registered) bool {
+			return p.Name == name
+		})
+		if !known {
+			timber.Warning("unknown provider", name, "in PROVIDERS")
+		}
+	}
+
+	var active []string
+	for _, p := range providers {
+		prefix := fmt.Sprintf("[%s]", p.Name)
+		if len(selected) != 0 && !slices.Contains(selected, p.Name) {
+			timber.Info(prefix, "disabled as it isn't listed in PROVIDERS")
+			continue
+		}
+		missing := secrets.Missing(p.RequiredSecrets...)
+		if len(missing) != 0 {
+			report := timber.Warning
+			if len(selected) != 0 {
+				report = timber.ErrorMsg
+			}
+			report(prefix, "disabled due to missing", strings.Join(missing, ", "))
+			continue
+		}
+
+		err := start(ctx, p, deps)
+		if err != nil {
+			timber.Error(err, prefix, "failed to setup; disabled")
+			continue
+		}
+		active = append(active, p.Name)
+	}
+
+	if len(active) == 0 {
+		timber.Warning("no providers are enabled")
+		return
+	}
+	timber.Done("enabled providers:", strings.Join(active, ", "))
+}
+
+// start starts the provider, turning a panic into an error so that it only disables the provider.
+func start(ctx context.Context, p cache.Registered, deps cache.Deps) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("setup panicked: %v", r)
+		}
+	}()
+	return p.Start(ctx, deps)
+}
@@ -1,28 +1,33 @@
 package applemusic
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"go.mattglei.ch/lcp/internal/apis"
 	"go.mattglei.ch/lcp/internal/secrets"
 )
 
-func sendAppleMusicAPIRequest[T any](client *http.Client, path string) (T, error) {
+func sendAppleMusicAPIRequest[T any](
+	ctx context.Context,
+	client *http.Client,
+	path string,
+) (T, error) {
 	var zeroValue T
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodGet,
-		fmt.Sprintf("https://api.music.apple.com/%s", strings.TrimLeft(path, "/")),
+		apis.URL(secrets.Get().AppleMusicURL, path),
 		nil,
 	)
 	if err != nil {
 		return zeroValue, fmt.Errorf("%w failed to create request", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+secrets.ENV.AppleMusicAppToken)
-	req.Header.Set("Music-User-Token", secrets.ENV.AppleMusicUserToken)
+	req.Header.Set("Authorization", "Bearer "+secrets.Get().AppleMusicAppToken)
+	req.Header.Set("Music-User-Token", secrets.Get().AppleMusicUserToken)
 
-	resp, err := apis.RequestJSON[T](cacheInstance.LogPrefix(), client, req)
+	resp, err := apis.RequestJSON[T](logPrefix, client, req)
 	if err != nil {
 		return zeroValue, fmt.Errorf("%w failed to make apple music API request", err)
 	}
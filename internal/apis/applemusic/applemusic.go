@@ -1,44 +1,74 @@
 package applemusic
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.mattglei.ch/lcp/internal/cache"
+	"go.mattglei.ch/lcp/internal/config"
+	"go.mattglei.ch/lcp/internal/tracing"
 	"go.mattglei.ch/lcp/pkg/lcp"
-	"go.mattglei.ch/timber"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-const cacheInstance = cache.AppleMusic
+const logPrefix = "[applemusic]"
 
-func cacheUpdate(client *http.Client, rdb *redis.Client) (lcp.AppleMusicCache, error) {
-	recentlyPlayed, err := fetchRecentlyPlayed(client, rdb)
+func init() {
+	cache.Register[lcp.AppleMusicCache](&provider{})
+}
+
+// provider caches recently played songs and a set of library playlists.
+type provider struct {
+	client *http.Client
+	rdb    *redis.Client
+}
+
+func (p *provider) Name() string {
+	return "applemusic"
+}
+
+func (p *provider) RequiredSecrets() []string {
+	return []string{"APPLE_MUSIC_APP_TOKEN", "APPLE_MUSIC_USER_TOKEN"}
+}
+
+func (p *provider) Setup(ctx context.Context, deps cache.Deps) error {
+	p.client, p.rdb = deps.Client, deps.Redis
+	return nil
+}
+
+func (p *provider) Fetch(ctx context.Context) (lcp.AppleMusicCache, error) {
+	return cacheUpdate(ctx, p.client, p.rdb)
+}
+
+func (p *provider) Interval() time.Duration {
+	return config.Get().AppleMusic.Interval
+}
+
+func (p *provider) Routes(mux *http.ServeMux, cache *cache.Cache[lcp.AppleMusicCache]) {}
+
+func cacheUpdate(
+	ctx context.Context,
+	client *http.Client,
+	rdb *redis.Client,
+) (lcp.AppleMusicCache, error) {
+	recentCtx, span := tracing.Start(ctx, "applemusic.fetchRecentlyPlayed")
+	recentlyPlayed, err := fetchRecentlyPlayed(recentCtx, client, rdb)
+	tracing.End(span, err)
 	if err != nil {
 		return lcp.AppleMusicCache{}, err
 	}
 
-	playlistsIDs := []string{
-		"p.AWXoZoxHLrvpJlY", // chill
-		"p.AWXoXPYSLrvpJlY", // alt
-		"p.qQXLX2rHA75zg8e", // after hours
-		"p.gek1E8efLa68Adp", // classics
-		"p.qQXLxPLtA75zg8e", // 80s
-		"p.V7VYVB0hZo53MQv", // old man
-		"p.LV0PXNoCl0EpDLW", // divorced dad
-		"p.QvDQE5RIVbAeokL", // party
-		"p.LV0PXL3Cl0EpDLW", // bops
-		"p.6xZaArOsvzb5OML", // focus
-		"p.AWXoXeAiLrvpJlY", // smooth
-		"p.O1kz7EoFVmvz704", // funk
-		"p.qQXLxPpFA75zg8e", // rap
-		"p.qQXLxpDuA75zg8e", // ROCK
-		"p.O1kz7zbsVmvz704", // country
-		"p.QvDQEN0IVbAeokL", // fall
-	}
 	playlists := []lcp.AppleMusicPlaylist{}
-	for _, id := range playlistsIDs {
-		playlistData, err := fetchPlaylist(client, rdb, id)
+	for _, id := range config.Get().AppleMusic.Playlists {
+		playlistCtx, span := tracing.Start(
+			ctx,
+			"applemusic.fetchPlaylist",
+			attribute.String("applemusic.playlist_id", id),
+		)
+		playlistData, err := fetchPlaylist(playlistCtx, client, rdb, id)
+		tracing.End(span, err)
 		if err != nil {
 			return lcp.AppleMusicCache{}, err
 		}
@@ -50,22 +80,3 @@ func cacheUpdate(client *http.Client, rdb *redis.Client) (lcp.AppleMusicCache, e
 		Playlists:      playlists,
 	}, nil
 }
-
-func Setup(mux *http.ServeMux, client *http.Client, rdb *redis.Client) {
-	data, err := cacheUpdate(client, rdb)
-	if err != nil {
-		timber.Error(err, "initial fetch of applemusic cache data failed")
-	}
-
-	applemusicCache := cache.New(cacheInstance, data, err == nil)
-	mux.HandleFunc("GET /applemusic", applemusicCache.ServeHTTP)
-	go cache.UpdatePeriodically(
-		applemusicCache,
-		client,
-		func(client *http.Client) (lcp.AppleMusicCache, error) {
-			return cacheUpdate(client, rdb)
-		},
-		30*time.Second,
-	)
-	timber.Done(cacheInstance.LogPrefix(), "setup cache and endpoints")
-}
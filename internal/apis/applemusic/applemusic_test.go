@@ -0,0 +1,65 @@
+package applemusic
+
+import (
+	"context"
+	"testing"
+
+	"go.mattglei.ch/lcp/internal/replay/replaytest"
+)
+
+func TestCacheUpdate(t *testing.T) {
+	client := replaytest.Setup(t, map[string]string{
+		"APPLE_MUSIC_APP_TOKEN":  "test-apple-music-app-token",
+		"APPLE_MUSIC_USER_TOKEN": "test-apple-music-user-token",
+		"APPLE_MUSIC_PLAYLISTS":  "p.test",
+	})
+	rdb := replaytest.Redis(t)
+
+	data, err := cacheUpdate(context.Background(), client, rdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.RecentlyPlayed) != 10 {
+		t.Fatalf("got %d recently played songs, want 10", len(data.RecentlyPlayed))
+	}
+	seen := map[string]bool{}
+	for _, song := range data.RecentlyPlayed {
+		if seen[song.ID] {
+			t.Errorf("song %s is in recently played more than once", song.ID)
+		}
+		seen[song.ID] = true
+		if song.AlbumArtBlurhash == "" {
+			t.Errorf("song %s has no album art blurhash", song.ID)
+		}
+	}
+	song := data.RecentlyPlayed[0]
+	if song.Track != "Song 1" || song.Artist != "Artist 1" || song.ID != "1001" {
+		t.Errorf("got first song %+v", song)
+	}
+	if song.AlbumArtURL != "https://is1-ssl.mzstatic.com/image/thumb/art/600x600.jpg" {
+		t.Errorf("got album art url %s", song.AlbumArtURL)
+	}
+	preview := "https://audio.example.com/1001.m4a"
+	if song.PreviewAudioURL == nil || *song.PreviewAudioURL != preview {
+		t.Errorf("got preview audio url %v, want %s", song.PreviewAudioURL, preview)
+	}
+
+	if len(data.Playlists) != 1 {
+		t.Fatalf("got %d playlists, want 1", len(data.Playlists))
+	}
+	playlist := data.Playlists[0]
+	if playlist.Name != "Test Playlist" || playlist.ID != "p.test" {
+		t.Errorf("got playlist %s (%s)", playlist.Name, playlist.ID)
+	}
+	if playlist.URL != "https://music.apple.com/us/playlist/alt/pl.test" {
+		t.Errorf("got playlist url %s", playlist.URL)
+	}
+	// the tracks are split across two pages
+	if len(playlist.Tracks) != 3 {
+		t.Fatalf("got %d playlist tracks, want 3", len(playlist.Tracks))
+	}
+	if playlist.Tracks[2].URL != "https://music.apple.com/us/song/song-3-remix/2003" {
+		t.Errorf("got url %s for track without one", playlist.Tracks[2].URL)
+	}
+}
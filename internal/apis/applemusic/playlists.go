@@ -1,6 +1,7 @@
 package applemusic
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
@@ -28,11 +29,13 @@ type playlistResponse struct {
 }
 
 func fetchPlaylist(
+	ctx context.Context,
 	client *http.Client,
 	rdb *redis.Client,
 	id string,
 ) (lcp.AppleMusicPlaylist, error) {
 	playlistData, err := sendAppleMusicAPIRequest[playlistResponse](
+		ctx,
 		client,
 		fmt.Sprintf("/v1/me/library/playlists/%s", id),
 	)
@@ -46,6 +49,7 @@ func fetchPlaylist(
 
 	var totalResponseData []songResponse
 	trackData, err := sendAppleMusicAPIRequest[playlistTracksResponse](
+		ctx,
 		client,
 		fmt.Sprintf("/v1/me/library/playlists/%s/tracks", id),
 	)
@@ -54,7 +58,11 @@ func fetchPlaylist(
 	}
 	totalResponseData = append(totalResponseData, trackData.Data...)
 	for trackData.Next != "" {
-		trackData, err = sendAppleMusicAPIRequest[playlistTracksResponse](client, trackData.Next)
+		trackData, err = sendAppleMusicAPIRequest[playlistTracksResponse](
+			ctx,
+			client,
+			trackData.Next,
+		)
 		if err != nil {
 			return lcp.AppleMusicPlaylist{}, fmt.Errorf(
 				"%w failed to paginate through tracks for playlist with id of %s",
@@ -67,7 +75,7 @@ func fetchPlaylist(
 
 	var tracks []lcp.AppleMusicSong
 	for _, t := range totalResponseData {
-		song, err := songFromSongResponse(client, rdb, t)
+		song, err := tracedSongFromSongResponse(ctx, client, rdb, t)
 		if err != nil {
 			return lcp.AppleMusicPlaylist{}, err
 		}
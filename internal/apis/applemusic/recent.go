@@ -1,6 +1,7 @@
 package applemusic
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -13,10 +14,12 @@ type recentlyPlayedResponse struct {
 }
 
 func fetchRecentlyPlayed(
+	ctx context.Context,
 	client *http.Client,
 	rdb *redis.Client,
 ) ([]lcp.AppleMusicSong, error) {
 	response, err := sendAppleMusicAPIRequest[recentlyPlayedResponse](
+		ctx,
 		client,
 		"/v1/me/recent/played/tracks",
 	)
@@ -26,7 +29,7 @@ func fetchRecentlyPlayed(
 
 	var songs []lcp.AppleMusicSong
 	for _, s := range response.Data {
-		so, err := songFromSongResponse(client, rdb, s)
+		so, err := tracedSongFromSongResponse(ctx, client, rdb, s)
 		if err != nil {
 			return []lcp.AppleMusicSong{}, fmt.Errorf(
 				"%w failed to parse song from song response",
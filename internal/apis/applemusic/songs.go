@@ -1,8 +1,11 @@
 package applemusic
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image/jpeg"
+	"io"
 	"math"
 	"net/http"
 	"net/url"
@@ -11,10 +14,12 @@ import (
 	"strings"
 
 	"github.com/redis/go-redis/v9"
-	"go.mattglei.ch/lcp/internal/cache"
+	"go.mattglei.ch/lcp/internal/apis"
 	"go.mattglei.ch/lcp/internal/images"
+	"go.mattglei.ch/lcp/internal/tracing"
 	"go.mattglei.ch/lcp/pkg/lcp"
 	"go.mattglei.ch/timber"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type songResponse struct {
@@ -44,14 +49,47 @@ type songResponse struct {
 	} `json:"attributes"`
 }
 
+// tracedSongFromSongResponse wraps songFromSongResponse in a span.
+func tracedSongFromSongResponse(
+	ctx context.Context,
+	client *http.Client,
+	rdb *redis.Client,
+	s songResponse,
+) (lcp.AppleMusicSong, error) {
+	ctx, span := tracing.Start(
+		ctx,
+		"applemusic.songFromSongResponse",
+		attribute.String("applemusic.song_id", s.ID),
+	)
+	song, err := songFromSongResponse(ctx, client, rdb, s)
+	tracing.End(span, err)
+	return song, err
+}
+
+// noArtworkError is returned when a song from the Apple Music API has an empty artwork URL, which
+// happens when its artwork fails to load.
+type noArtworkError struct {
+	songID string
+}
+
+func (e *noArtworkError) Error() string {
+	return fmt.Sprintf("artwork failed to load for song %s", e.songID)
+}
+
+// Is reports the error as non-critical because it is expected and clears up on its own.
+func (e *noArtworkError) Is(target error) bool {
+	return target == apis.ErrWarning
+}
+
 func songFromSongResponse(
+	ctx context.Context,
 	client *http.Client,
 	rdb *redis.Client,
 	s songResponse,
 ) (lcp.AppleMusicSong, error) {
 	if s.Attributes.Artwork.URL == "" {
-		timber.Warning(cacheInstance.LogPrefix(), "empty artwork url error")
-		return lcp.AppleMusicSong{}, cache.ErrAppleMusicNoArtwork
+		timber.Warning(logPrefix, "empty artwork url error")
+		return lcp.AppleMusicSong{}, &noArtworkError{songID: s.ID}
 	}
 
 	if s.Attributes.URL == "" {
@@ -80,9 +118,9 @@ func songFromSongResponse(
 	if s.Attributes.PlayParams.CatalogID != "" {
 		id = s.Attributes.PlayParams.CatalogID
 	}
-	blurhash, err := images.BlurHash(client, rdb, artURL, jpeg.Decode)
-	if err != nil && strings.Contains(err.Error(), "unexpected EOF") {
-		timber.Warning("failed to create blur hash for", albumArtURL)
+	blurhash, err := images.BlurHash(ctx, client, rdb, artURL, jpeg.Decode)
+	if err != nil && errors.Is(err, io.ErrUnexpectedEOF) {
+		timber.Warning("failed to create blur hash for", artURL)
 	} else if err != nil {
 		return lcp.AppleMusicSong{}, fmt.Errorf("%w failed to get blur hash for %s: \"%s\"", err, id, s.Attributes.Name)
 	}
@@ -0,0 +1,135 @@
+package apis
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mattglei.ch/lcp/internal/metrics"
+	"go.mattglei.ch/timber"
+)
+
+const (
+	// breakerThreshold is how many requests to a host must fail in a row for its circuit to open.
+	breakerThreshold = 5
+	// breakerCooldown is how long a circuit stays open before a single request is let through to
+	// probe if the host has recovered.
+	breakerCooldown = time.Minute
+)
+
+// CircuitOpenError is returned without sending a request when a host's circuit is open because
+// recent requests to it kept failing.
+type CircuitOpenError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf(
+		"circuit for %s is open until %s",
+		e.Host,
+		e.Until.Format(time.RFC3339),
+	)
+}
+
+// Is reports an open circuit as a non-critical error so that it is only logged as a warning.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrWarning
+}
+
+type breakerState int
+
+const (
+	closed breakerState = iota
+	open
+	halfOpen
+)
+
+// breaker tracks the health of a single host. A closed breaker lets every request through, an
+// open breaker rejects every request until breakerCooldown has passed, and a half-open breaker
+// lets a single probe through which either closes or reopens it.
+type breaker struct {
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+var (
+	breakersMutex sync.Mutex
+	breakers      = map[string]*breaker{}
+)
+
+// allow reports if a request can be sent to host. If not, the time when the next probe will be
+// let through is returned.
+func allow(host string) (time.Time, bool) {
+	breakersMutex.Lock()
+	defer breakersMutex.Unlock()
+	b, ok := breakers[host]
+	if !ok {
+		return time.Time{}, true
+	}
+	switch b.state {
+	case open:
+		until := b.openedAt.Add(breakerCooldown)
+		if time.Now().Before(until) {
+			return until, false
+		}
+		b.state = halfOpen
+		return time.Time{}, true
+	case halfOpen:
+		// a probe is already in flight
+		return b.openedAt.Add(breakerCooldown), false
+	}
+	return time.Time{}, true
+}
+
+// report records the result of a request that allow let through.
+func report(logPrefix string, host string, failed bool) {
+	breakersMutex.Lock()
+	defer breakersMutex.Unlock()
+	b, ok := breakers[host]
+	if !ok {
+		if !failed {
+			return
+		}
+		b = &breaker{}
+		breakers[host] = b
+	}
+
+	if !failed {
+		if b.state != closed {
+			timber.Done(logPrefix, "circuit for", host, "closed after successful probe")
+			metrics.ObserveCircuit(host, false)
+		}
+		delete(breakers, host)
+		return
+	}
+
+	b.failures++
+	if b.state == halfOpen || b.failures >= breakerThreshold {
+		if b.state == closed {
+			timber.Warning(
+				logPrefix,
+				"circuit for",
+				host,
+				"opened after",
+				b.failures,
+				"consecutive failures",
+			)
+			metrics.ObserveCircuit(host, true)
+		}
+		b.state = open
+		b.openedAt = time.Now()
+	}
+}
+
+// abandon releases a request that allow let through without recording a result, such as one
+// that was cancelled by its caller.
+func abandon(host string) {
+	breakersMutex.Lock()
+	defer breakersMutex.Unlock()
+	b, ok := breakers[host]
+	if ok && b.state == halfOpen {
+		b.state = open
+	}
+}
@@ -0,0 +1,161 @@
+package apis
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// breakerStep is an action taken on a host's breaker followed by the state that the breaker must
+// be in afterwards.
+type breakerStep struct {
+	action string
+	// allowed is whether allow must let the request through for the "allow" action.
+	allowed bool
+	state   breakerState
+}
+
+const (
+	// stepAllow asks the breaker if a request can be sent.
+	stepAllow = "allow"
+	// stepFail and stepSucceed report the result of a request.
+	stepFail    = "fail"
+	stepSucceed = "succeed"
+	// stepAbandon releases a request without a result.
+	stepAbandon = "abandon"
+	// stepCool moves the time that the circuit opened back so that the cooldown has passed.
+	stepCool = "cool"
+)
+
+func TestBreaker(t *testing.T) {
+	// opening takes breakerThreshold failures in a row
+	var opening []breakerStep
+	for range breakerThreshold - 1 {
+		opening = append(
+			opening,
+			breakerStep{action: stepAllow, allowed: true, state: closed},
+			breakerStep{action: stepFail, state: closed},
+		)
+	}
+	opening = append(
+		opening,
+		breakerStep{action: stepAllow, allowed: true, state: closed},
+		breakerStep{action: stepFail, state: open},
+		breakerStep{action: stepAllow, allowed: false, state: open},
+	)
+
+	tests := []struct {
+		name  string
+		steps []breakerStep
+	}{
+		{
+			name: "success keeps the circuit closed",
+			steps: []breakerStep{
+				{action: stepAllow, allowed: true, state: closed},
+				{action: stepSucceed, state: closed},
+				{action: stepAllow, allowed: true, state: closed},
+			},
+		},
+		{
+			name: "success resets failures",
+			steps: append(
+				opening[:len(opening)-3:len(opening)-3],
+				breakerStep{action: stepSucceed, state: closed},
+				breakerStep{action: stepAllow, allowed: true, state: closed},
+				breakerStep{action: stepFail, state: closed},
+			),
+		},
+		{
+			name:  "failures open the circuit",
+			steps: opening,
+		},
+		{
+			name: "successful probe closes the circuit",
+			steps: append(
+				opening[:len(opening):len(opening)],
+				breakerStep{action: stepCool, state: open},
+				breakerStep{action: stepAllow, allowed: true, state: halfOpen},
+				breakerStep{action: stepAllow, allowed: false, state: halfOpen},
+				breakerStep{action: stepSucceed, state: closed},
+				breakerStep{action: stepAllow, allowed: true, state: closed},
+			),
+		},
+		{
+			name: "failed probe reopens the circuit",
+			steps: append(
+				opening[:len(opening):len(opening)],
+				breakerStep{action: stepCool, state: open},
+				breakerStep{action: stepAllow, allowed: true, state: halfOpen},
+				breakerStep{action: stepFail, state: open},
+				breakerStep{action: stepAllow, allowed: false, state: open},
+			),
+		},
+		{
+			name: "abandoned probe lets another probe through",
+			steps: append(
+				opening[:len(opening):len(opening)],
+				breakerStep{action: stepCool, state: open},
+				breakerStep{action: stepAllow, allowed: true, state: halfOpen},
+				breakerStep{action: stepAbandon, state: open},
+				breakerStep{action: stepAllow, allowed: true, state: halfOpen},
+				breakerStep{action: stepSucceed, state: closed},
+			),
+		},
+		{
+			name: "abandon leaves a closed circuit alone",
+			steps: []breakerStep{
+				{action: stepAllow, allowed: true, state: closed},
+				{action: stepAbandon, state: closed},
+				{action: stepAllow, allowed: true, state: closed},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := strings.ReplaceAll(tt.name, " ", "-") + ".test"
+			t.Cleanup(func() {
+				breakersMutex.Lock()
+				delete(breakers, host)
+				breakersMutex.Unlock()
+			})
+
+			for i, step := range tt.steps {
+				switch step.action {
+				case stepAllow:
+					until, ok := allow(host)
+					if ok != step.allowed {
+						t.Fatalf("step %d: got allowed %t, want %t", i, ok, step.allowed)
+					}
+					if !ok && step.state == open && !until.After(time.Now()) {
+						t.Fatalf("step %d: got next probe at %s, which has passed", i, until)
+					}
+				case stepFail, stepSucceed:
+					report("[test]", host, step.action == stepFail)
+				case stepAbandon:
+					abandon(host)
+				case stepCool:
+					breakersMutex.Lock()
+					breakers[host].openedAt = time.Now().Add(-breakerCooldown)
+					breakersMutex.Unlock()
+				}
+				if state := breakerStateOf(host); state != step.state {
+					t.Fatalf(
+						"step %d (%s): got state %d, want %d",
+						i, step.action, state, step.state,
+					)
+				}
+			}
+		})
+	}
+}
+
+// breakerStateOf returns the state of host's breaker, which is closed if host has no breaker.
+func breakerStateOf(host string) breakerState {
+	breakersMutex.Lock()
+	defer breakersMutex.Unlock()
+	b, ok := breakers[host]
+	if !ok {
+		return closed
+	}
+	return b.state
+}
@@ -0,0 +1,121 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// snippetLength is how many bytes of a failed response's body are kept in an UpstreamError.
+const snippetLength = 256
+
+// UpstreamError describes a request to an upstream API that failed, either because a response
+// couldn't be received or because the response wasn't successful. Use errors.As to inspect it.
+// Every UpstreamError except for non-transient network errors is non-critical (see ErrWarning).
+type UpstreamError struct {
+	Host string
+	// StatusCode is the status code of the response or 0 if no response was received.
+	StatusCode int
+	// Retryable is true if the same request is likely to succeed if it is sent again later.
+	Retryable bool
+	// RateLimitedUntil is when requests to Host can resume if the host rate limited us.
+	RateLimitedUntil time.Time
+	// Snippet is the start of the response's body.
+	Snippet string
+	// Err is the error that caused the request to fail if no response was received.
+	Err error
+}
+
+func (e *UpstreamError) Error() string {
+	switch {
+	case e.StatusCode != 0:
+		msg := fmt.Sprintf(
+			"%d (%s) from %s",
+			e.StatusCode,
+			strings.ToLower(http.StatusText(e.StatusCode)),
+			e.Host,
+		)
+		if e.Snippet != "" {
+			msg += fmt.Sprintf(": \"%s\"", e.Snippet)
+		}
+		return msg
+	case e.Err != nil:
+		return fmt.Sprintf("%v sending request to %s failed", e.Err, e.Host)
+	case e.RateLimited():
+		return fmt.Sprintf(
+			"rate limited by %s until %s",
+			e.Host,
+			e.RateLimitedUntil.Format(time.RFC3339),
+		)
+	}
+	return fmt.Sprintf("request to %s failed", e.Host)
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports the error as non-critical unless it is a network error that isn't transient.
+func (e *UpstreamError) Is(target error) bool {
+	return target == ErrWarning && (e.StatusCode != 0 || e.Retryable || e.RateLimited())
+}
+
+// RateLimited reports if the host rate limited us.
+func (e *UpstreamError) RateLimited() bool {
+	return !e.RateLimitedUntil.IsZero()
+}
+
+// Reason describes a transient network error for log messages.
+func (e *UpstreamError) Reason() string {
+	var netErr net.Error
+	switch {
+	case errors.Is(e.Err, context.DeadlineExceeded):
+		return "request timed out"
+	case errors.As(e.Err, &netErr) && netErr.Timeout():
+		return "connection timed out"
+	case errors.Is(e.Err, io.ErrUnexpectedEOF):
+		return "unexpected EOF"
+	case errors.Is(e.Err, syscall.ECONNRESET):
+		return "tcp connection reset by peer"
+	}
+	return e.Error()
+}
+
+// TransportError wraps an error from sending a request to host that didn't receive a response.
+func TransportError(host string, err error) *UpstreamError {
+	return &UpstreamError{Host: host, Retryable: transient(err), Err: err}
+}
+
+// StatusError creates an error for an unsuccessful response with the given body.
+func StatusError(resp *http.Response, body []byte) *UpstreamError {
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) > snippetLength {
+		snippet = snippet[:snippetLength]
+		for !utf8.ValidString(snippet) {
+			snippet = snippet[:len(snippet)-1]
+		}
+	}
+	return &UpstreamError{
+		Host:       resp.Request.URL.Host,
+		StatusCode: resp.StatusCode,
+		Retryable:  retryableStatus(resp.StatusCode),
+		Snippet:    snippet,
+	}
+}
+
+// transient reports if err is a network error that is likely to succeed if the request is sent
+// again.
+func transient(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
@@ -7,28 +7,59 @@ import (
 
 	"github.com/shurcooL/githubv4"
 	"go.mattglei.ch/lcp/internal/cache"
+	"go.mattglei.ch/lcp/internal/config"
+	"go.mattglei.ch/lcp/internal/replay"
 	"go.mattglei.ch/lcp/internal/secrets"
-	"go.mattglei.ch/timber"
+	"go.mattglei.ch/lcp/internal/tracing"
+	"go.mattglei.ch/lcp/pkg/lcp"
 	"golang.org/x/oauth2"
 )
 
-const cacheInstance = cache.GitHub
+const logPrefix = "[github]"
 
-func Setup(mux *http.ServeMux) {
-	githubTokenSource := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: secrets.ENV.GitHubAccessToken},
-	)
-	githubHttpClient := oauth2.NewClient(context.Background(), githubTokenSource)
-	githubClient := githubv4.NewClient(githubHttpClient)
+func init() {
+	cache.Register[[]lcp.GitHubRepository](&provider{})
+}
+
+// provider caches the repositories pinned to the github profile.
+type provider struct {
+	client *githubv4.Client
+}
+
+func (p *provider) Name() string {
+	return "github"
+}
+
+func (p *provider) RequiredSecrets() []string {
+	return []string{"GITHUB_ACCESS_TOKEN"}
+}
 
-	pinnedRepos, err := fetchPinnedRepos(githubClient)
-	if err != nil {
-		timber.Error(err, "fetching initial pinned repos failed")
+func (p *provider) Setup(ctx context.Context, deps cache.Deps) error {
+	// the transport is used directly instead of oauth2.NewClient so the token isn't reused after
+	// it has been rotated
+	httpClient := &http.Client{
+		Transport: &oauth2.Transport{
+			Source: tokenSource{},
+			Base:   tracing.Transport(replay.Transport(http.DefaultTransport)),
+		},
 	}
+	p.client = githubv4.NewEnterpriseClient(secrets.Get().GitHubGraphQLURL, httpClient)
+	return nil
+}
+
+func (p *provider) Fetch(ctx context.Context) ([]lcp.GitHubRepository, error) {
+	return fetchPinnedRepos(ctx, p.client)
+}
+
+func (p *provider) Interval() time.Duration {
+	return config.Get().GitHub.Interval
+}
+
+func (p *provider) Routes(mux *http.ServeMux, cache *cache.Cache[[]lcp.GitHubRepository]) {}
 
-	githubCache := cache.New(cacheInstance, pinnedRepos, err == nil)
-	mux.HandleFunc("GET /github", githubCache.ServeHTTP)
-	go cache.UpdatePeriodically(githubCache, githubClient, fetchPinnedRepos, 30*time.Second)
+// tokenSource provides the current GitHub access token so that a rotated token is picked up.
+type tokenSource struct{}
 
-	timber.Done(cacheInstance.LogPrefix(), "setup cache and endpoint")
+func (tokenSource) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: secrets.Get().GitHubAccessToken}, nil
 }
@@ -2,14 +2,12 @@ package github
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net"
-	"strings"
-	"syscall"
+	"net/url"
 
 	"github.com/shurcooL/githubv4"
 	"go.mattglei.ch/lcp/internal/apis"
+	"go.mattglei.ch/lcp/internal/secrets"
 	"go.mattglei.ch/lcp/pkg/lcp"
 	"go.mattglei.ch/timber"
 )
@@ -38,20 +36,31 @@ type pinnedItemsQuery struct {
 	}
 }
 
-func fetchPinnedRepos(client *githubv4.Client) ([]lcp.GitHubRepository, error) {
-	var query pinnedItemsQuery
-	err := client.Query(context.Background(), &query, nil)
-	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-		timber.Warning(cacheInstance.LogPrefix(), "connection timed out for getting pinned repos")
-		return []lcp.GitHubRepository{}, apis.ErrWarning
-	}
-	if err != nil && (errors.Is(err, syscall.ECONNRESET) ||
-		strings.Contains(err.Error(), "connection reset by peer")) {
-		timber.Warning(cacheInstance.LogPrefix(),
-			"connection reset by peer while getting pinned repos")
-		return []lcp.GitHubRepository{}, apis.ErrWarning
+// graphQLHost returns the host of the configured GitHub GraphQL endpoint.
+func graphQLHost() string {
+	u, err := url.Parse(secrets.Get().GitHubGraphQLURL)
+	if err != nil {
+		return secrets.Get().GitHubGraphQLURL
 	}
+	return u.Host
+}
+
+func fetchPinnedRepos(
+	ctx context.Context,
+	client *githubv4.Client,
+) ([]lcp.GitHubRepository, error) {
+	var query pinnedItemsQuery
+	err := client.Query(ctx, &query, nil)
 	if err != nil {
+		upstreamErr := apis.TransportError(graphQLHost(), err)
+		if upstreamErr.Retryable {
+			timber.Warning(
+				logPrefix,
+				upstreamErr.Reason(),
+				"while getting pinned repos",
+			)
+			return []lcp.GitHubRepository{}, upstreamErr
+		}
 		return nil, fmt.Errorf("%w querying github's graphql API failed", err)
 	}
 
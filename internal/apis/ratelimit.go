@@ -0,0 +1,131 @@
+package apis
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxAttempts is how many times an idempotent request is sent before giving up.
+	maxAttempts = 3
+	// retryBaseDelay is the delay before the first retry when the server doesn't send a
+	// Retry-After header. It doubles with every attempt.
+	retryBaseDelay = time.Second
+	// maxRetryWait is the longest that a request will wait before being retried. Servers that ask
+	// for a longer wait have their host paused instead so that the updater isn't blocked.
+	maxRetryWait = 10 * time.Second
+	// defaultRateLimitPause is how long a host is paused after a 429 without a Retry-After header.
+	defaultRateLimitPause = time.Minute
+)
+
+var (
+	pausedMutex sync.Mutex
+	paused      = map[string]time.Time{}
+)
+
+// pause stops requests from being sent to host until the given time.
+func pause(host string, until time.Time) {
+	pausedMutex.Lock()
+	defer pausedMutex.Unlock()
+	if until.After(paused[host]) {
+		paused[host] = until
+	}
+}
+
+// pausedUntil returns when requests to host can resume if it is currently paused.
+func pausedUntil(host string) (time.Time, bool) {
+	pausedMutex.Lock()
+	defer pausedMutex.Unlock()
+	until, ok := paused[host]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !time.Now().Before(until) {
+		delete(paused, host)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// idempotent reports if req can be safely sent again.
+func idempotent(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.Body == nil
+}
+
+// retryableStatus reports if a response with the given status code is worth retrying.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before sending the given attempt again, preferring the
+// server's Retry-After header. Delays are jittered by up to 20% so that updaters that failed
+// together don't retry together.
+func retryDelay(attempt int, header http.Header) time.Duration {
+	if delay, ok := parseRetryAfter(header.Get("Retry-After")); ok {
+		return delay
+	}
+	delay := retryBaseDelay << (attempt - 1)
+	return delay + time.Duration(rand.Float64()*0.2*float64(delay))
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of seconds or an HTTP
+// date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	date, err := http.ParseTime(value)
+	if err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// observeRateLimit pauses host once Strava's X-RateLimit-Usage reaches X-RateLimit-Limit. Both
+// headers hold the usage or limit for the 15 minute window followed by the daily window (e.g.
+// "200,2000"). The windows reset at the next quarter hour and at midnight UTC.
+func observeRateLimit(host string, header http.Header) {
+	for _, prefix := range []string{"X-RateLimit", "X-ReadRateLimit"} {
+		limits := parseRateLimitHeader(header.Get(prefix + "-Limit"))
+		usage := parseRateLimitHeader(header.Get(prefix + "-Usage"))
+		if len(limits) < 2 || len(usage) < 2 {
+			continue
+		}
+		now := time.Now().UTC()
+		if usage[1] >= limits[1] {
+			pause(host, now.Truncate(24*time.Hour).Add(24*time.Hour))
+		} else if usage[0] >= limits[0] {
+			pause(host, now.Truncate(15*time.Minute).Add(15*time.Minute))
+		}
+	}
+}
+
+func parseRateLimitHeader(value string) []int {
+	if value == "" {
+		return nil
+	}
+	var values []int
+	for _, part := range strings.Split(value, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil
+		}
+		values = append(values, v)
+	}
+	return values
+}
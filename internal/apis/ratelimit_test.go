@@ -0,0 +1,284 @@
+package apis
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "empty", value: ""},
+		{name: "seconds", value: "30", want: 30 * time.Second, wantOk: true},
+		{name: "padded seconds", value: " 5 ", want: 5 * time.Second, wantOk: true},
+		{name: "zero", value: "0", want: 0, wantOk: true},
+		{name: "negative seconds", value: "-3", want: 0, wantOk: true},
+		{
+			name:   "date",
+			value:  time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat),
+			want:   2 * time.Minute,
+			wantOk: true,
+		},
+		{
+			name:   "past date",
+			value:  time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat),
+			want:   0,
+			wantOk: true,
+		},
+		{name: "invalid", value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value)
+			if ok != tt.wantOk {
+				t.Fatalf("got ok %t, want %t", ok, tt.wantOk)
+			}
+			// dates only have second precision
+			if diff := (got - tt.want).Abs(); diff > time.Second {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObserveRateLimit(t *testing.T) {
+	var (
+		quarterHour = func(now time.Time) time.Time {
+			return now.Truncate(15 * time.Minute).Add(15 * time.Minute)
+		}
+		midnight = func(now time.Time) time.Time {
+			return now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+		}
+	)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		// want returns when the host is paused until or nil if it shouldn't be paused.
+		want func(now time.Time) time.Time
+	}{
+		{name: "no headers"},
+		{
+			name: "under both limits",
+			headers: map[string]string{
+				"X-RateLimit-Limit": "200,2000",
+				"X-RateLimit-Usage": "150,1500",
+			},
+		},
+		{
+			name: "15 minute limit",
+			headers: map[string]string{
+				"X-RateLimit-Limit": "200,2000",
+				"X-RateLimit-Usage": "200,1500",
+			},
+			want: quarterHour,
+		},
+		{
+			name: "daily limit",
+			headers: map[string]string{
+				"X-RateLimit-Limit": "200,2000",
+				"X-RateLimit-Usage": "120,2000",
+			},
+			want: midnight,
+		},
+		{
+			name: "both limits",
+			headers: map[string]string{
+				"X-RateLimit-Limit": "200,2000",
+				"X-RateLimit-Usage": "201,2001",
+			},
+			want: midnight,
+		},
+		{
+			name: "read limit",
+			headers: map[string]string{
+				"X-RateLimit-Limit":     "200,2000",
+				"X-RateLimit-Usage":     "10,100",
+				"X-ReadRateLimit-Limit": "100,1000",
+				"X-ReadRateLimit-Usage": "100,500",
+			},
+			want: quarterHour,
+		},
+		{
+			name: "malformed",
+			headers: map[string]string{
+				"X-RateLimit-Limit": "200,2000",
+				"X-RateLimit-Usage": "200,lots",
+			},
+		},
+		{
+			name: "single window",
+			headers: map[string]string{
+				"X-RateLimit-Limit": "200",
+				"X-RateLimit-Usage": "200",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := strings.ReplaceAll(tt.name, " ", "-") + ".test"
+			t.Cleanup(func() { unpause(host) })
+			header := http.Header{}
+			for key, value := range tt.headers {
+				header.Set(key, value)
+			}
+
+			observeRateLimit(host, header)
+			until, ok := pausedUntil(host)
+			if tt.want == nil {
+				if ok {
+					t.Fatalf("got paused until %s, want not paused", until)
+				}
+				return
+			}
+			if want := tt.want(time.Now().UTC()); !ok || !until.Equal(want) {
+				t.Fatalf("got paused until %s (%t), want %s", until, ok, want)
+			}
+		})
+	}
+}
+
+// rateLimitResponse is a response sent by the test server in TestRequestRateLimited.
+type rateLimitResponse struct {
+	status     int
+	retryAfter string
+}
+
+func TestRequestRateLimited(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		responses []rateLimitResponse
+		// wantRequests is how many requests must reach the server.
+		wantRequests int32
+		wantErr      bool
+		// wantPause is about how long the host must be paused for or 0 if it shouldn't be.
+		wantPause time.Duration
+	}{
+		{
+			name:   "retried after a short wait",
+			method: http.MethodGet,
+			responses: []rateLimitResponse{
+				{status: http.StatusTooManyRequests, retryAfter: "0"},
+				{status: http.StatusOK},
+			},
+			wantRequests: 2,
+		},
+		{
+			name:   "paused after a long wait",
+			method: http.MethodGet,
+			responses: []rateLimitResponse{
+				{status: http.StatusTooManyRequests, retryAfter: "120"},
+			},
+			wantRequests: 1,
+			wantErr:      true,
+			wantPause:    2 * time.Minute,
+		},
+		{
+			name:   "paused until a date",
+			method: http.MethodGet,
+			responses: []rateLimitResponse{
+				{
+					status: http.StatusTooManyRequests,
+					retryAfter: time.Now().
+						Add(5 * time.Minute).
+						UTC().
+						Format(http.TimeFormat),
+				},
+			},
+			wantRequests: 1,
+			wantErr:      true,
+			wantPause:    5 * time.Minute,
+		},
+		{
+			name:   "gives up after every attempt",
+			method: http.MethodGet,
+			responses: []rateLimitResponse{
+				{status: http.StatusTooManyRequests, retryAfter: "0"},
+				{status: http.StatusTooManyRequests, retryAfter: "0"},
+				{status: http.StatusTooManyRequests, retryAfter: "0"},
+			},
+			wantRequests: maxAttempts,
+			wantErr:      true,
+			// a Retry-After of 0 pauses the host until now so it is never paused
+		},
+		{
+			name:   "not retried without Retry-After",
+			method: http.MethodPost,
+			responses: []rateLimitResponse{
+				{status: http.StatusTooManyRequests},
+			},
+			wantRequests: 1,
+			wantErr:      true,
+			wantPause:    defaultRateLimitPause,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					i := min(int(requests.Add(1)), len(tt.responses)) - 1
+					resp := tt.responses[i]
+					if resp.retryAfter != "" {
+						w.Header().Set("Retry-After", resp.retryAfter)
+					}
+					w.WriteHeader(resp.status)
+				}),
+			)
+			defer server.Close()
+			req, err := http.NewRequest(tt.method, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { unpause(req.URL.Host) })
+
+			_, err = Request("[test]", server.Client(), req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Fatalf("got %d requests, want %d", got, tt.wantRequests)
+			}
+
+			until, paused := pausedUntil(req.URL.Host)
+			if tt.wantPause == 0 {
+				if paused {
+					t.Fatalf("got paused until %s, want not paused", until)
+				}
+				return
+			}
+			if diff := (time.Until(until) - tt.wantPause).Abs(); !paused || diff > 2*time.Second {
+				t.Fatalf("got paused until %s (%t), want about %s", until, paused, tt.wantPause)
+			}
+			var upstreamErr *UpstreamError
+			if !errors.As(err, &upstreamErr) || !upstreamErr.RateLimitedUntil.Equal(until) {
+				t.Fatalf("got error %v, want it to be rate limited until %s", err, until)
+			}
+
+			// requests to a paused host aren't sent
+			_, err = Request("[test]", server.Client(), req)
+			if !errors.As(err, &upstreamErr) || !upstreamErr.RateLimited() {
+				t.Fatalf("got error %v while paused, want rate limited error", err)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Fatalf("got %d requests while paused, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// unpause removes host's pause so that it doesn't affect other tests.
+func unpause(host string) {
+	pausedMutex.Lock()
+	delete(paused, host)
+	pausedMutex.Unlock()
+}
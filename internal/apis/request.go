@@ -6,12 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"go.mattglei.ch/lcp/internal/metrics"
+	"go.mattglei.ch/lcp/internal/tracing"
 	"go.mattglei.ch/timber"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // ErrWarning indicates that a non-critical error occurred during a request. Although the error
@@ -21,51 +24,161 @@ import (
 var ErrWarning = errors.New("non-critical error encountered during request")
 
 // Request sends an HTTP request using the provided client with a 1-minute timeout and returns
-// the response body as a byte slice. It handles common transient network errors—including timeouts,
-// unexpected EOFs, and TCP connection resets—by logging warnings and returning a non-critical
-// WarningError. Non-2xx HTTP responses are also treated as warnings.
+// the response body as a byte slice. Failed requests return an UpstreamError. It handles common
+// transient network errors—including timeouts, unexpected EOFs, and TCP connection resets—by
+// logging warnings and returning an error that is non-critical (see ErrWarning). Non-2xx HTTP
+// responses are also treated as warnings. Idempotent requests that fail with a transient error, a
+// 429, or a 502-504 are retried with backoff, honoring Retry-After. A host that rate limits us is
+// paused and an UpstreamError with RateLimitedUntil set is returned until it can be retried.
+// Hosts that keep failing have their circuit opened and a CircuitOpenError is returned without
+// sending anything until a probe succeeds.
 func Request(logPrefix string, client *http.Client, req *http.Request) ([]byte, error) {
 	ctx, cancel := context.WithTimeout(req.Context(), 1*time.Minute)
 	defer cancel()
+	ctx, span := tracing.Start(
+		ctx,
+		"apis.Request",
+		attribute.String("lcp.log_prefix", logPrefix),
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Host),
+		attribute.String("url.path", req.URL.Path),
+	)
 	req = req.WithContext(ctx)
 
-	resp, err := client.Do(req)
-	if err != nil {
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			timber.Warning(logPrefix, "connection timed out for", req.URL.Path)
-			return []byte{}, ErrWarning
+	var (
+		start   = time.Now()
+		code    int
+		retries int
+		outcome = metrics.Error
+	)
+	defer func() {
+		metrics.ObserveUpstreamRequest(logPrefix, code, outcome, start)
+		span.SetAttributes(
+			attribute.String("lcp.outcome", outcome),
+			attribute.Int("http.request.resend_count", retries),
+		)
+		if code != 0 {
+			span.SetAttributes(attribute.Int("http.response.status_code", code))
 		}
-		if errors.Is(err, context.DeadlineExceeded) {
-			timber.Warning(logPrefix, "request timed out for", req.URL.Path)
-			return []byte{}, ErrWarning
+		if outcome != metrics.Success {
+			span.SetStatus(codes.Error, outcome)
 		}
-		if errors.Is(err, io.ErrUnexpectedEOF) {
-			timber.Warning(logPrefix, "unexpected EOF from", req.URL.Path)
-			return []byte{}, ErrWarning
+		span.End()
+	}()
+
+	host := req.URL.Host
+	if until, ok := pausedUntil(host); ok {
+		timber.Warning(logPrefix, "skipping request to", host, "until", until.Format(time.Kitchen))
+		outcome = metrics.Warning
+		return []byte{}, &UpstreamError{Host: host, Retryable: true, RateLimitedUntil: until}
+	}
+	if until, ok := allow(host); !ok {
+		outcome = metrics.Warning
+		return []byte{}, &CircuitOpenError{Host: host, Until: until}
+	}
+
+	for attempt := 1; ; attempt++ {
+		retries = attempt - 1
+		canRetry := attempt < maxAttempts && idempotent(req) && ctx.Err() == nil
+
+		resp, err := client.Do(req)
+		var body []byte
+		if err == nil {
+			code = resp.StatusCode
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				err = fmt.Errorf("%w reading response body failed", err)
+			}
 		}
-		if strings.Contains(err.Error(), "read: connection reset by peer") {
-			timber.Warning(logPrefix, "tcp connection reset by peer from", req.URL.Path)
-			return []byte{}, ErrWarning
+		if err != nil {
+			upstreamErr := TransportError(host, err)
+			if canRetry && upstreamErr.Retryable &&
+				wait(ctx, logPrefix, req, upstreamErr.Reason(), retryDelay(attempt, nil)) {
+				continue
+			}
+			if errors.Is(err, context.Canceled) {
+				abandon(host)
+			} else {
+				report(logPrefix, host, true)
+			}
+			if upstreamErr.Retryable {
+				timber.Warning(logPrefix, upstreamErr.Reason(), "for", req.URL.Path)
+				outcome = metrics.Warning
+			}
+			return []byte{}, upstreamErr
 		}
-		return []byte{}, fmt.Errorf("%w sending request to %s failed", err, req.URL.String())
-	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return []byte{}, fmt.Errorf("%w reading response body failed", err)
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		timber.Warning(
-			logPrefix,
+		observeRateLimit(host, resp.Header)
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			report(logPrefix, host, false)
+			outcome = metrics.Success
+			return body, nil
+		}
+
+		upstreamErr := StatusError(resp, body)
+		status := fmt.Sprintf(
+			"%d (%s)",
 			resp.StatusCode,
-			fmt.Sprintf("(%s)", strings.ToLower(http.StatusText(resp.StatusCode))),
-			"from",
-			req.URL.String(),
+			strings.ToLower(http.StatusText(resp.StatusCode)),
 		)
-		return []byte{}, ErrWarning
+		if canRetry && upstreamErr.Retryable {
+			delay := retryDelay(attempt, resp.Header)
+			if delay <= maxRetryWait && wait(ctx, logPrefix, req, status, delay) {
+				continue
+			}
+		}
+
+		// only server errors count towards opening the circuit as other errors are specific to
+		// the request that was sent
+		report(logPrefix, host, resp.StatusCode >= 500)
+		timber.Warning(logPrefix, status, "from", req.URL.String())
+		outcome = metrics.Warning
+		if resp.StatusCode == http.StatusTooManyRequests {
+			delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"))
+			if !ok {
+				delay = defaultRateLimitPause
+			}
+			pause(host, time.Now().Add(delay))
+		}
+		if until, ok := pausedUntil(host); ok {
+			upstreamErr.RateLimitedUntil = until
+		}
+		return []byte{}, upstreamErr
 	}
-	return body, nil
+}
+
+// wait sleeps for delay before a request is retried, returning false if ctx ends first.
+func wait(
+	ctx context.Context,
+	logPrefix string,
+	req *http.Request,
+	reason string,
+	delay time.Duration,
+) bool {
+	timber.Warning(
+		logPrefix,
+		"retrying",
+		req.URL.Path,
+		"in",
+		delay.Round(time.Millisecond),
+		"after",
+		reason,
+	)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// URL joins the base URL of an upstream API, which is configurable so that it can be pointed at a
+// stand-in server or proxy, with the path of an endpoint.
+func URL(base string, path string) string {
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(path, "/")
 }
 
 // RequestJSON sends an HTTP request using the provided client, reads the response body, and
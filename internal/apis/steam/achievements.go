@@ -1,6 +1,7 @@
 package steam
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -39,18 +40,33 @@ type schemaGameResponse struct {
 }
 
 func fetchGameAchievements(
+	ctx context.Context,
 	client *http.Client,
 	appID int32,
 ) (*float32, *[]lcp.SteamAchievement, error) {
 	params := url.Values{
-		"key":     {secrets.ENV.SteamKey},
-		"steamid": {secrets.ENV.SteamID},
+		"key":     {secrets.Get().SteamKey},
+		"steamid": {secrets.Get().SteamID},
 		"appid":   {fmt.Sprint(appID)},
 		"format":  {"json"},
 	}
-	resp, err := client.Get(
-		"https://api.steampowered.com/ISteamUserStats/GetPlayerAchievements/v0001?" + params.Encode(),
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		apis.URL(
+			secrets.Get().SteamURL,
+			"/ISteamUserStats/GetPlayerAchievements/v0001?"+params.Encode(),
+		),
+		nil,
 	)
+	if err != nil {
+		return nil, nil, fmt.Errorf(
+			"%v creating request for player achievements failed for app id: %d",
+			err,
+			appID,
+		)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, nil, fmt.Errorf(
 			"%v sending request for player achievements from %d failed",
@@ -78,7 +94,7 @@ func fetchGameAchievements(
 			"returned from API. Code of 200 expected from",
 			resp.Request.URL.String(),
 		)
-		return nil, nil, apis.ErrWarning
+		return nil, nil, apis.StatusError(resp, body)
 	}
 
 	var playerAchievements playerAchievementsResponse
@@ -94,13 +110,14 @@ func fetchGameAchievements(
 	}
 
 	params = url.Values{
-		"key":    {secrets.ENV.SteamKey},
+		"key":    {secrets.Get().SteamKey},
 		"appid":  {fmt.Sprint(appID)},
 		"format": {"json"},
 	}
-	req, err := http.NewRequest(
+	req, err = http.NewRequestWithContext(
+		ctx,
 		http.MethodGet,
-		"https://api.steampowered.com/ISteamUserStats/GetSchemaForGame/v2?"+params.Encode(),
+		apis.URL(secrets.Get().SteamURL, "/ISteamUserStats/GetSchemaForGame/v2?"+params.Encode()),
 		nil,
 	)
 	if err != nil {
@@ -110,7 +127,7 @@ func fetchGameAchievements(
 			appID,
 		)
 	}
-	gameSchema, err := apis.RequestJSON[schemaGameResponse](cacheInstance.LogPrefix(), client, req)
+	gameSchema, err := apis.RequestJSON[schemaGameResponse](logPrefix, client, req)
 	if err != nil {
 		return nil, nil, fmt.Errorf("%w failed to get game schema for app id: %d", err, appID)
 	}
@@ -1,6 +1,7 @@
 package steam
 
 import (
+	"context"
 	"fmt"
 	"image/jpeg"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"go.mattglei.ch/lcp/internal/apis"
+	"go.mattglei.ch/lcp/internal/config"
 	"go.mattglei.ch/lcp/internal/images"
 	"go.mattglei.ch/lcp/internal/secrets"
 	"go.mattglei.ch/lcp/pkg/lcp"
@@ -27,22 +29,27 @@ type ownedGamesResponse struct {
 	} `json:"response"`
 }
 
-func fetchRecentlyPlayedGames(client *http.Client, rdb *redis.Client) ([]lcp.SteamGame, error) {
+func fetchRecentlyPlayedGames(
+	ctx context.Context,
+	client *http.Client,
+	rdb *redis.Client,
+) ([]lcp.SteamGame, error) {
 	params := url.Values{
-		"key":             {secrets.ENV.SteamKey},
-		"steamid":         {secrets.ENV.SteamID},
+		"key":             {secrets.Get().SteamKey},
+		"steamid":         {secrets.Get().SteamID},
 		"include_appinfo": {"true"},
 	}
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodGet,
-		"https://api.steampowered.com/IPlayerService/GetOwnedGames/v1/?"+params.Encode(),
+		apis.URL(secrets.Get().SteamURL, "/IPlayerService/GetOwnedGames/v1/?"+params.Encode()),
 		nil,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("%w failed to create request for steam API owned games", err)
 	}
 	ownedGames, err := apis.RequestJSON[ownedGamesResponse](
-		cacheInstance.LogPrefix(),
+		logPrefix,
 		client,
 		req,
 	)
@@ -55,8 +62,9 @@ func fetchRecentlyPlayedGames(client *http.Client, rdb *redis.Client) ([]lcp.Ste
 	})
 
 	var games []lcp.SteamGame
-	for _, g := range ownedGames.Response.Games[:10] {
-		achievementPercentage, achievements, err := fetchGameAchievements(client, g.AppID)
+	limit := min(config.Get().Steam.Games, len(ownedGames.Response.Games))
+	for _, g := range ownedGames.Response.Games[:limit] {
+		achievementPercentage, achievements, err := fetchGameAchievements(ctx, client, g.AppID)
 		if err != nil {
 			return nil, err
 		}
@@ -65,7 +73,7 @@ func fetchRecentlyPlayedGames(client *http.Client, rdb *redis.Client) ([]lcp.Ste
 			"https://shared.akamai.steamstatic.com/store_item_assets/steam/apps/%d/header.jpg",
 			g.AppID,
 		)
-		headerBlurHash, err := images.BlurHash(client, rdb, headerURL, jpeg.Decode)
+		headerBlurHash, err := images.BlurHash(ctx, client, rdb, headerURL, jpeg.Decode)
 		if err != nil {
 			return nil, fmt.Errorf("%w failed to load blurhash image data for library hero", err)
 		}
@@ -1,32 +1,47 @@
 package steam
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.mattglei.ch/lcp/internal/cache"
+	"go.mattglei.ch/lcp/internal/config"
 	"go.mattglei.ch/lcp/pkg/lcp"
-	"go.mattglei.ch/timber"
 )
 
-const cacheInstance = cache.Steam
-
-func Setup(mux *http.ServeMux, client *http.Client, rdb *redis.Client) {
-	games, err := fetchRecentlyPlayedGames(client, rdb)
-	if err != nil {
-		timber.Error(err, "initial fetch of steam games failed")
-	}
-
-	steamCache := cache.New(cacheInstance, games, err == nil)
-	mux.HandleFunc("GET /steam", steamCache.ServeHTTP)
-	go cache.UpdatePeriodically(
-		steamCache,
-		client,
-		func(client *http.Client) ([]lcp.SteamGame, error) {
-			return fetchRecentlyPlayedGames(client, rdb)
-		},
-		15*time.Minute,
-	)
-	timber.Done(cacheInstance.LogPrefix(), "setup cache and endpoint")
+const logPrefix = "[steam]"
+
+func init() {
+	cache.Register[[]lcp.SteamGame](&provider{})
+}
+
+// provider caches the most recently played steam games.
+type provider struct {
+	client *http.Client
+	rdb    *redis.Client
+}
+
+func (p *provider) Name() string {
+	return "steam"
+}
+
+func (p *provider) RequiredSecrets() []string {
+	return []string{"STEAM_KEY", "STEAM_ID"}
 }
+
+func (p *provider) Setup(ctx context.Context, deps cache.Deps) error {
+	p.client, p.rdb = deps.Client, deps.Redis
+	return nil
+}
+
+func (p *provider) Fetch(ctx context.Context) ([]lcp.SteamGame, error) {
+	return fetchRecentlyPlayedGames(ctx, p.client, p.rdb)
+}
+
+func (p *provider) Interval() time.Duration {
+	return config.Get().Steam.Interval
+}
+
+func (p *provider) Routes(mux *http.ServeMux, cache *cache.Cache[[]lcp.SteamGame]) {}
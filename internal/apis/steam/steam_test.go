@@ -0,0 +1,54 @@
+package steam
+
+import (
+	"context"
+	"testing"
+
+	"go.mattglei.ch/lcp/internal/replay/replaytest"
+)
+
+func TestFetchRecentlyPlayedGames(t *testing.T) {
+	client := replaytest.Setup(t, map[string]string{
+		"STEAM_KEY":   "test-steam-key",
+		"STEAM_ID":    "76561190000000000",
+		"STEAM_GAMES": "2",
+	})
+	rdb := replaytest.Redis(t)
+
+	games, err := fetchRecentlyPlayedGames(context.Background(), client, rdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the two most recently played of the three owned games are kept
+	if len(games) != 2 {
+		t.Fatalf("got %d games, want 2", len(games))
+	}
+	if games[0].Name != "Portal 2" || games[1].Name != "Half-Life" {
+		t.Errorf("got games %s and %s", games[0].Name, games[1].Name)
+	}
+
+	portal := games[0]
+	if portal.HeaderBlurHash == "" {
+		t.Error("got no header blurhash")
+	}
+	// achievements that aren't in the game's schema are ignored
+	want := float32(2) / 3 * 100
+	if portal.AchievementProgress == nil || *portal.AchievementProgress != want {
+		t.Errorf("got achievement progress %v, want %v", portal.AchievementProgress, want)
+	}
+	if portal.Achievements == nil || len(*portal.Achievements) != 3 {
+		t.Fatalf("got achievements %v, want 3", portal.Achievements)
+	}
+	// the most recently unlocked achievement is first
+	achievement := (*portal.Achievements)[0]
+	if achievement.ApiName != "ACH_WAKE_UP" || !achievement.Achieved ||
+		achievement.DisplayName != "Wake Up Call" {
+		t.Errorf("got first achievement %+v", achievement)
+	}
+
+	// games without stats have no achievements rather than failing
+	if games[1].AchievementProgress != nil || games[1].Achievements != nil {
+		t.Errorf("got achievements for %s, which has no stats", games[1].Name)
+	}
+}
@@ -1,6 +1,7 @@
 package workouts
 
 import (
+	"context"
 	"fmt"
 	"image/png"
 	"net/http"
@@ -11,22 +12,24 @@ import (
 	"github.com/redis/go-redis/v9"
 	"go.mattglei.ch/lcp/internal/apis/workouts/hevy"
 	"go.mattglei.ch/lcp/internal/apis/workouts/strava"
+	"go.mattglei.ch/lcp/internal/config"
 	"go.mattglei.ch/lcp/internal/images"
 	"go.mattglei.ch/lcp/pkg/lcp"
 )
 
 func fetch(
+	ctx context.Context,
 	client *http.Client,
 	minioClient *minio.Client,
 	rdb *redis.Client,
 	stravaTokens strava.Tokens,
 ) ([]lcp.Workout, error) {
-	stravaActivities, err := strava.FetchActivities(client, minioClient, rdb, stravaTokens)
+	stravaActivities, err := strava.FetchActivities(ctx, client, minioClient, rdb, stravaTokens)
 	if err != nil {
 		return []lcp.Workout{}, err
 	}
 
-	hevyWorkouts, err := hevy.FetchWorkouts(client)
+	hevyWorkouts, err := hevy.FetchWorkouts(ctx, client)
 	if err != nil {
 		return []lcp.Workout{}, err
 	}
@@ -55,8 +58,8 @@ func fetch(
 		return activities[i].StartDate.After(activities[j].StartDate)
 	})
 
-	// only store the first 20 activities
-	activities = activities[:20]
+	// only store the most recent activities
+	activities = activities[:min(config.Get().Workouts.Limit, len(activities))]
 
 	// fill in data for collected strava activities. this is done to keep the number of API requests
 	// to strava to a minimum. Rate limits were getting hit when making requests for all strava
@@ -68,7 +71,7 @@ func fetch(
 			continue
 		}
 
-		details, err := strava.FetchActivityDetails(client, activity.ID, stravaTokens)
+		details, err := strava.FetchActivityDetails(ctx, client, activity.ID, stravaTokens)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"%w failed to fetch activity details for activity with ID of %s",
@@ -78,7 +81,7 @@ func fetch(
 		}
 		activity.Calories = details.Calories
 
-		heartrateStream, err := strava.FetchHeartrate(client, activity.ID, stravaTokens)
+		heartrateStream, err := strava.FetchHeartrate(ctx, client, activity.ID, stravaTokens)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"%w failed to fetch HR data for activity with ID of %s",
@@ -89,19 +92,16 @@ func fetch(
 		activity.HeartrateData = heartrateStream
 
 		if activity.HasMap {
-			mapData, err := strava.FetchMap(client, activity.MapPolyline)
+			mapData, err := strava.FetchMap(ctx, client, activity.MapPolyline)
 			if err != nil {
 				return nil, fmt.Errorf("%w failed to fetch map", err)
 			}
-			err = strava.UploadMap(minioClient, activity.ID, mapData)
+			err = strava.UploadMap(ctx, minioClient, activity.ID, mapData)
 			if err != nil {
 				return nil, fmt.Errorf("%w failed to upload map", err)
 			}
-			imgURL := fmt.Sprintf(
-				"https://s3.mattglei.ch/mapbox-maps/%s.png",
-				activity.ID,
-			)
-			mapBlurHash, err := images.BlurHash(client, rdb, imgURL, png.Decode)
+			imgURL := strava.MapURL(activity.ID)
+			mapBlurHash, err := images.BlurHash(ctx, client, rdb, imgURL, png.Decode)
 			if err != nil {
 				return nil, fmt.Errorf("%w failed to create blur hash for image", err)
 			}
@@ -110,7 +110,7 @@ func fetch(
 		}
 	}
 
-	err = strava.RemoveOldMaps(minioClient, activities)
+	err = strava.RemoveOldMaps(ctx, minioClient, activities)
 	if err != nil {
 		return nil, fmt.Errorf("%w failed to remove old maps", err)
 	}
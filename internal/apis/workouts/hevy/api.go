@@ -1,25 +1,26 @@
 package hevy
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"go.mattglei.ch/lcp/internal/apis"
 	"go.mattglei.ch/lcp/internal/secrets"
 )
 
-func sendHevyAPIRequest[T any](client *http.Client, path string) (T, error) {
+func sendHevyAPIRequest[T any](ctx context.Context, client *http.Client, path string) (T, error) {
 	var zeroValue T
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodGet,
-		fmt.Sprintf("https://api.hevyapp.com/%s", strings.TrimLeft(path, "/")),
+		apis.URL(secrets.Get().HevyURL, path),
 		nil,
 	)
 	if err != nil {
 		return zeroValue, fmt.Errorf("%w failed to create request", err)
 	}
-	req.Header.Set("api-key", secrets.ENV.HevyAccessToken)
+	req.Header.Set("api-key", secrets.Get().HevyAccessToken)
 
 	resp, err := apis.RequestJSON[T](logPrefix, client, req)
 	if err != nil {
@@ -1,6 +1,7 @@
 package hevy
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -8,6 +9,7 @@ import (
 
 	"slices"
 
+	"go.mattglei.ch/lcp/internal/config"
 	"go.mattglei.ch/lcp/internal/secrets"
 	"go.mattglei.ch/lcp/pkg/lcp"
 )
@@ -23,9 +25,10 @@ type workoutsResponse struct {
 	} `json:"workouts"`
 }
 
-func FetchWorkouts(client *http.Client) ([]lcp.Workout, error) {
-	params := url.Values{"api-key": {secrets.ENV.HevyAccessToken}}
+func FetchWorkouts(ctx context.Context, client *http.Client) ([]lcp.Workout, error) {
+	params := url.Values{"api-key": {secrets.Get().HevyAccessToken}}
 	workouts, err := sendHevyAPIRequest[workoutsResponse](
+		ctx,
 		client,
 		fmt.Sprintf("/v1/workouts?%s", params.Encode()),
 	)
@@ -33,10 +36,7 @@ func FetchWorkouts(client *http.Client) ([]lcp.Workout, error) {
 		return []lcp.Workout{}, fmt.Errorf("%w failed to fetch hevy workouts", err)
 	}
 
-	bodyWeightExercises := []string{
-		"Chest Dip (Assisted)",
-		"Pull Up (Assisted)",
-	}
+	bodyWeightExercises := config.Get().Workouts.BodyWeightExercises
 
 	var activities []lcp.Workout
 	for _, workout := range workouts.Workouts {
@@ -46,7 +46,7 @@ func FetchWorkouts(client *http.Client) ([]lcp.Workout, error) {
 			for i, set := range exercise.Sets {
 				// account for bodyweight exercises which are (body weight - weight)
 				if slices.Contains(bodyWeightExercises, exercise.Title) {
-					totalVolume += (secrets.ENV.HevyBodyWeightLBS*0.45359237 - set.WeightKg) * float64(set.Reps)
+					totalVolume += (secrets.Get().HevyBodyWeightLBS*0.45359237 - set.WeightKg) * float64(set.Reps)
 					exercise.Sets[i].WeightKg = -set.WeightKg
 				} else {
 					totalVolume += set.WeightKg * float64(set.Reps)
@@ -1,6 +1,7 @@
 package strava
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -50,12 +51,14 @@ type detailedStravaActivity struct {
 }
 
 func FetchActivities(
+	ctx context.Context,
 	client *http.Client,
 	minioClient *minio.Client,
 	rdb *redis.Client,
 	tokens Tokens,
 ) ([]lcp.Workout, error) {
 	stravaActivities, err := sendStravaAPIRequest[[]StravaActivity](
+		ctx,
 		client,
 		"api/v3/athlete/activities",
 		tokens,
@@ -90,13 +93,19 @@ func FetchActivities(
 	return activities, nil
 }
 
-func FetchHeartrate(client *http.Client, id string, tokens Tokens) ([]int, error) {
+func FetchHeartrate(
+	ctx context.Context,
+	client *http.Client,
+	id string,
+	tokens Tokens,
+) ([]int, error) {
 	params := url.Values{
 		"key_by_type": {"true"},
 		"keys":        {"heartrate"},
 		"resolution":  {"low"},
 	}
 	stream, err := sendStravaAPIRequest[struct{ Heartrate activityStream }](
+		ctx,
 		client,
 		fmt.Sprintf("api/v3/activities/%s/streams?%s", id, params.Encode()),
 		tokens,
@@ -113,11 +122,13 @@ func FetchHeartrate(client *http.Client, id string, tokens Tokens) ([]int, error
 }
 
 func FetchActivityDetails(
+	ctx context.Context,
 	client *http.Client,
 	id string,
 	tokens Tokens,
 ) (detailedStravaActivity, error) {
 	details, err := sendStravaAPIRequest[detailedStravaActivity](
+		ctx,
 		client,
 		fmt.Sprintf("api/v3/activities/%s", id),
 		tokens,
@@ -1,19 +1,26 @@
 package strava
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"go.mattglei.ch/lcp/internal/apis"
+	"go.mattglei.ch/lcp/internal/secrets"
 )
 
-func sendStravaAPIRequest[T any](client *http.Client, path string, tokens Tokens) (T, error) {
+func sendStravaAPIRequest[T any](
+	ctx context.Context,
+	client *http.Client,
+	path string,
+	tokens Tokens,
+) (T, error) {
 	var zeroValue T
 
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodGet,
-		fmt.Sprintf("https://www.strava.com/%s", strings.TrimLeft(path, "/")),
+		apis.URL(secrets.Get().StravaURL, path),
 		nil,
 	)
 	if err != nil {
@@ -1,13 +1,13 @@
 package strava
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 
-	"github.com/minio/minio-go/v7"
-	"github.com/redis/go-redis/v9"
 	"go.mattglei.ch/lcp/internal/cache"
+	"go.mattglei.ch/lcp/internal/cluster"
 	"go.mattglei.ch/lcp/internal/secrets"
 	"go.mattglei.ch/lcp/pkg/lcp"
 	"go.mattglei.ch/timber"
@@ -23,16 +23,11 @@ type event struct {
 	Updates        map[string]string `json:"updates"`
 }
 
+// EventRoute handles strava webhook events by updating workoutsCache with update. Followers
+// forward events to the leader so that only the leader calls upstream APIs.
 func EventRoute(
-	client *http.Client,
 	workoutsCache *cache.Cache[[]lcp.Workout],
-	minioClient *minio.Client,
-	rdb *redis.Client,
-	fetch func(client *http.Client,
-		minioClient *minio.Client,
-		rdb *redis.Client,
-		stravaTokens Tokens) ([]lcp.Workout, error),
-	tokens Tokens,
+	update func(ctx context.Context) ([]lcp.Workout, error),
 ) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		body, err := io.ReadAll(r.Body)
@@ -50,29 +45,70 @@ func EventRoute(
 			return
 		}
 
-		if eventData.SubscriptionID != secrets.ENV.StravaSubscriptionID {
+		if eventData.SubscriptionID != secrets.Get().StravaSubscriptionID {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
 
-		err = tokens.RefreshIfNeeded(client)
-		if err != nil {
-			timber.Error(err)
+		if !cluster.IsLeader() {
+			err = cluster.Forward(r.Context(), workoutsCache.Name(), body)
+			if err != nil {
+				timber.Error(err, "failed to forward strava event to leader")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
 			return
 		}
 
-		activities, err := fetch(client, minioClient, rdb, tokens)
-		if err != nil {
-			timber.ErrorMsg("failed to update strava cache")
-			return
+		// the update shouldn't be interrupted if strava disconnects, but shutdown still waits for
+		// it to finish
+		tracked := cache.Track(r.Context(), func(ctx context.Context) {
+			handleEvent(ctx, workoutsCache, update)
+		})
+		if !tracked {
+			// strava resends events that fail so the next instance handles it
+			w.WriteHeader(http.StatusServiceUnavailable)
 		}
-		workoutsCache.Update(activities)
 	})
 }
 
+// FollowForwardedEvents handles the events that followers forward while this instance is the
+// leader.
+func FollowForwardedEvents(
+	workoutsCache *cache.Cache[[]lcp.Workout],
+	update func(ctx context.Context) ([]lcp.Workout, error),
+) {
+	events, err := cluster.Forwarded(context.Background(), workoutsCache.Name())
+	if err != nil {
+		timber.Error(err, "failed to follow forwarded strava events")
+		return
+	}
+	for range events {
+		tracked := cache.Track(context.Background(), func(ctx context.Context) {
+			handleEvent(ctx, workoutsCache, update)
+		})
+		if !tracked {
+			timber.Warning(logPrefix, "dropping forwarded strava event; shutting down")
+			return
+		}
+	}
+}
+
+func handleEvent(
+	ctx context.Context,
+	workoutsCache *cache.Cache[[]lcp.Workout],
+	update func(ctx context.Context) ([]lcp.Workout, error),
+) {
+	activities, err := update(ctx)
+	if err != nil {
+		timber.Error(err, "failed to update strava cache")
+		return
+	}
+	workoutsCache.Update(activities)
+}
+
 func ChallengeRoute(w http.ResponseWriter, r *http.Request) {
 	verifyToken := r.URL.Query().Get("hub.verify_token")
-	if verifyToken != secrets.ENV.StravaVerifyToken {
+	if verifyToken != secrets.Get().StravaVerifyToken {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
@@ -7,37 +7,40 @@ import (
 	"net/http"
 	"net/url"
 	"slices"
+	"strings"
 
 	"github.com/minio/minio-go/v7"
 	"go.mattglei.ch/lcp/internal/apis"
+	"go.mattglei.ch/lcp/internal/config"
+	"go.mattglei.ch/lcp/internal/metrics"
 	"go.mattglei.ch/lcp/internal/secrets"
 	"go.mattglei.ch/lcp/pkg/lcp"
 	"go.mattglei.ch/timber"
 )
 
-const bucketName = "mapbox-maps"
+// MapURL is where the map for the activity with id is served from publicly.
+func MapURL(id string) string {
+	m := config.Get().Workouts.Map
+	return fmt.Sprintf("%s/%s/%s.png", strings.TrimSuffix(m.PublicURL, "/"), m.Bucket, id)
+}
 
-func FetchMap(client *http.Client, polyline string) ([]byte, error) {
+func FetchMap(ctx context.Context, client *http.Client, polyline string) ([]byte, error) {
 	var (
-		lineWidth = 2.0
-		lineColor = "000"
-		width     = 462
-		height    = 252
-		style     = "mattgleich/clxxsfdfm002401qj7jcxh47e"
-		params    = url.Values{"access_token": {secrets.ENV.MapboxAccessToken}}
-		url       = fmt.Sprintf(
-			"https://api.mapbox.com/styles/v1/%s/static/path-%f+%s(%s)/auto/%dx%d@2x?%s",
-			style,
-			lineWidth,
-			lineColor,
+		m      = config.Get().Workouts.Map
+		params = url.Values{"access_token": {secrets.Get().MapboxAccessToken}}
+		url    = apis.URL(secrets.Get().MapboxURL, fmt.Sprintf(
+			"/styles/v1/%s/static/path-%f+%s(%s)/auto/%dx%d@2x?%s",
+			m.Style,
+			m.LineWidth,
+			m.LineColor,
 			url.QueryEscape(polyline),
-			width,
-			height,
+			m.Width,
+			m.Height,
 			params.Encode(),
-		)
+		))
 	)
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("%w failed to create get request to %s", err, url)
 	}
@@ -51,44 +54,51 @@ func FetchMap(client *http.Client, polyline string) ([]byte, error) {
 	return b, nil
 }
 
-func UploadMap(minioClient *minio.Client, id string, data []byte) error {
+func UploadMap(ctx context.Context, minioClient *minio.Client, id string, data []byte) error {
 	var (
 		reader = bytes.NewReader(data)
 		size   = int64(len(data))
 	)
 
 	_, err := minioClient.PutObject(
-		context.Background(),
-		bucketName,
+		ctx,
+		config.Get().Workouts.Map.Bucket,
 		fmt.Sprintf("%s.png", id),
 		reader,
 		size,
 		minio.PutObjectOptions{ContentType: "image/png"},
 	)
+	metrics.ObserveMinioOperation("upload", err)
 	if err != nil {
 		return fmt.Errorf("%w failed to upload to minio", err)
 	}
 	return nil
 }
 
-func RemoveOldMaps(minioClient *minio.Client, activities []lcp.Workout) error {
+func RemoveOldMaps(
+	ctx context.Context,
+	minioClient *minio.Client,
+	activities []lcp.Workout,
+) error {
 	var validKeys []string
 	for _, activity := range activities {
 		validKeys = append(validKeys, fmt.Sprintf("%s.png", activity.ID))
 	}
 
-	objects := minioClient.ListObjects(context.Background(), bucketName, minio.ListObjectsOptions{})
+	bucket := config.Get().Workouts.Map.Bucket
+	objects := minioClient.ListObjects(ctx, bucket, minio.ListObjectsOptions{})
 	for object := range objects {
 		if object.Err != nil {
 			return fmt.Errorf("%w failed to load object", object.Err)
 		}
 		if !slices.Contains(validKeys, object.Key) {
 			err := minioClient.RemoveObject(
-				context.Background(),
-				bucketName,
+				ctx,
+				bucket,
 				object.Key,
 				minio.RemoveObjectOptions{},
 			)
+			metrics.ObserveMinioOperation("remove", err)
 			if err != nil {
 				return fmt.Errorf("%w failed to remove object", err)
 			}
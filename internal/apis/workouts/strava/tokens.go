@@ -1,6 +1,7 @@
 package strava
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -19,28 +20,29 @@ type Tokens struct {
 
 func LoadTokens() Tokens {
 	return Tokens{
-		Access:    secrets.ENV.StravaAccessToken,
-		Refresh:   secrets.ENV.StravaRefreshToken,
+		Access:    secrets.Get().StravaAccessToken,
+		Refresh:   secrets.Get().StravaRefreshToken,
 		ExpiresAt: 0, // starts at zero to force a refresh on boot
 	}
 }
 
-func (t *Tokens) RefreshIfNeeded(client *http.Client) error {
+func (t *Tokens) RefreshIfNeeded(ctx context.Context, client *http.Client) error {
 	// subtract 60 to ensure that token doesn't expire in the next 60 seconds
 	if t.ExpiresAt-60 >= time.Now().Unix() {
 		return nil
 	}
 
 	params := url.Values{
-		"client_id":     {secrets.ENV.StravaClientID},
-		"client_secret": {secrets.ENV.StravaClientSecret},
+		"client_id":     {secrets.Get().StravaClientID},
+		"client_secret": {secrets.Get().StravaClientSecret},
 		"grant_type":    {"refresh_token"},
 		"refresh_token": {t.Refresh},
-		"code":          {secrets.ENV.StravaOAuthCode},
+		"code":          {secrets.Get().StravaOAuthCode},
 	}
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
-		"https://www.strava.com/oauth/token?"+params.Encode(),
+		apis.URL(secrets.Get().StravaURL, "/oauth/token?"+params.Encode()),
 		nil,
 	)
 	if err != nil {
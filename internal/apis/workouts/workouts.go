@@ -1,48 +1,114 @@
 package workouts
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/redis/go-redis/v9"
 	"go.mattglei.ch/lcp/internal/apis/workouts/strava"
 	"go.mattglei.ch/lcp/internal/cache"
-	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/lcp/internal/cluster"
+	"go.mattglei.ch/lcp/internal/ratelimit"
+	"go.mattglei.ch/lcp/internal/s3"
+	"go.mattglei.ch/lcp/pkg/lcp"
 	"go.mattglei.ch/timber"
 )
 
-const cacheInstance = cache.Workouts
+func init() {
+	cache.Register[[]lcp.Workout](&provider{})
+}
+
+// provider caches the most recent strava and hevy workouts. It is updated by strava's webhook
+// events rather than periodically.
+type provider struct {
+	client      *http.Client
+	rdb         *redis.Client
+	minioClient *minio.Client
+
+	// tokens are refreshed by every fetch, which can run concurrently from strava events and the
+	// admin API
+	tokens      strava.Tokens
+	tokensMutex sync.Mutex
+}
+
+func (p *provider) Name() string {
+	return "workouts"
+}
 
-func Setup(mux *http.ServeMux, client *http.Client, rdb *redis.Client) {
-	stravaTokens := strava.LoadTokens()
-	err := stravaTokens.RefreshIfNeeded(client)
+func (p *provider) RequiredSecrets() []string {
+	return []string{
+		"STRAVA_CLIENT_ID",
+		"STRAVA_CLIENT_SECRET",
+		"STRAVA_REFRESH_TOKEN",
+		"STRAVA_SUBSCRIPTION_ID",
+		"STRAVA_VERIFY_TOKEN",
+		"MAPBOX_ACCESS_TOKEN",
+		"HEVY_ACCESS_TOKEN",
+		"MINIO_ENDPOINT",
+		"MINIO_ACCESS_KEY_ID",
+		"MINIO_SECRET_KEY",
+	}
+}
+
+func (p *provider) Setup(ctx context.Context, deps cache.Deps) error {
+	minioClient, err := s3.NewClient()
 	if err != nil {
-		timber.Error(err, "failed to refresh strava token data on boot")
+		return fmt.Errorf("%w failed to create minio client", err)
 	}
-	minioClient, err := minio.New(secrets.ENV.MinioEndpoint, &minio.Options{
-		Creds: credentials.NewStaticV4(
-			secrets.ENV.MinioAccessKeyID,
-			secrets.ENV.MinioSecretKey,
-			"",
-		),
-		Secure: true,
-	})
+	p.client, p.rdb, p.minioClient = deps.Client, deps.Redis, minioClient
+	p.tokens = strava.LoadTokens()
+	_, err = p.refreshTokens(ctx)
 	if err != nil {
-		timber.Fatal(err, "failed to create minio client")
+		timber.Error(err, "failed to refresh strava token data on boot")
 	}
-	activities, err := fetch(client, minioClient, rdb, stravaTokens)
+	return nil
+}
+
+func (p *provider) Fetch(ctx context.Context) ([]lcp.Workout, error) {
+	tokens, err := p.refreshTokens(ctx)
 	if err != nil {
-		timber.Error(err, "failed to load initial data for workouts cache; not updating")
+		return nil, err
 	}
-	workoutsCache := cache.New(cacheInstance, activities, err == nil)
+	return fetch(ctx, p.client, p.minioClient, p.rdb, tokens)
+}
 
-	mux.HandleFunc("GET /workouts", workoutsCache.ServeHTTP)
+// refreshTokens refreshes the strava tokens if they are about to expire, returning a copy of them.
+func (p *provider) refreshTokens(ctx context.Context) (strava.Tokens, error) {
+	p.tokensMutex.Lock()
+	defer p.tokensMutex.Unlock()
+	err := p.tokens.RefreshIfNeeded(ctx, p.client)
+	return p.tokens, err
+}
+
+func (p *provider) Interval() time.Duration {
+	return 0
+}
+
+func (p *provider) Routes(mux *http.ServeMux, workoutsCache *cache.Cache[[]lcp.Workout]) {
 	mux.HandleFunc(
 		"POST /strava/event",
-		strava.EventRoute(client, workoutsCache, minioClient, rdb, fetch, stravaTokens),
+		ratelimit.LimitByIP(
+			strava.EventRoute(workoutsCache, p.Fetch),
+		),
 	)
-	mux.HandleFunc("GET /strava/event", strava.ChallengeRoute)
+	mux.HandleFunc("GET /strava/event", ratelimit.LimitByIP(strava.ChallengeRoute))
+	if cluster.Enabled() {
+		go strava.FollowForwardedEvents(workoutsCache, p.Fetch)
+	}
+}
 
-	timber.Done(cacheInstance.LogPrefix(), "setup cache and endpoints")
+// Redact removes health data from workouts that are served without a token.
+func (p *provider) Redact(workouts []lcp.Workout) []lcp.Workout {
+	redacted := make([]lcp.Workout, len(workouts))
+	for i, workout := range workouts {
+		workout.HeartrateData = nil
+		workout.AverageHeartrate = 0
+		workout.Calories = 0
+		redacted[i] = workout
+	}
+	return redacted
 }
@@ -0,0 +1,73 @@
+package workouts
+
+import (
+	"context"
+	"testing"
+
+	"go.mattglei.ch/lcp/internal/apis/workouts/strava"
+	"go.mattglei.ch/lcp/internal/replay/replaytest"
+	"go.mattglei.ch/lcp/internal/s3"
+)
+
+func TestFetch(t *testing.T) {
+	client := replaytest.Setup(t, map[string]string{
+		"STRAVA_ACCESS_TOKEN": "test-strava-access-token",
+		"HEVY_ACCESS_TOKEN":   "test-hevy-access-token",
+		"MAPBOX_ACCESS_TOKEN": "test-mapbox-access-token",
+		"MINIO_ENDPOINT":      "minio.example.com",
+		"MINIO_ACCESS_KEY_ID": "test-minio-access-key-id",
+		"MINIO_SECRET_KEY":    "test-minio-secret-key",
+	})
+	minioClient, err := s3.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdb := replaytest.Redis(t)
+
+	workouts, err := fetch(
+		context.Background(),
+		client,
+		minioClient,
+		rdb,
+		strava.Tokens{Access: "test-strava-access-token"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the private strava activity is skipped and the one that was also recorded with hevy is
+	// replaced by the hevy workout
+	if len(workouts) != 2 {
+		t.Fatalf("got %d workouts, want 2", len(workouts))
+	}
+	run, lift := workouts[0], workouts[1]
+	if run.Platform != "strava" || run.ID != "101" || lift.Platform != "hevy" {
+		t.Fatalf(
+			"got %s workout %s and %s workout %s",
+			run.Platform,
+			run.ID,
+			lift.Platform,
+			lift.ID,
+		)
+	}
+
+	if run.Calories != 512 {
+		t.Errorf("got %v calories, want 512", run.Calories)
+	}
+	if len(run.HeartrateData) != 4 {
+		t.Errorf("got %d heartrate samples, want 4", len(run.HeartrateData))
+	}
+	if run.MapImageURL == nil || *run.MapImageURL != "https://s3.mattglei.ch/mapbox-maps/101.png" {
+		t.Errorf("got map image url %v", run.MapImageURL)
+	}
+	if run.MapBlurImage == nil || *run.MapBlurImage == "" {
+		t.Error("got no map blurhash")
+	}
+
+	if lift.Name != "Push Day" || lift.HevySetCount != 3 {
+		t.Errorf("got hevy workout %s with %d sets", lift.Name, lift.HevySetCount)
+	}
+	if lift.HevyVolumeKG != 1200 {
+		t.Errorf("got hevy volume of %vkg, want 1200kg", lift.HevyVolumeKG)
+	}
+}
@@ -1,27 +1,55 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
 
-	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/lcp/internal/ratelimit"
 )
 
-func IsAuthorized(w http.ResponseWriter, r *http.Request) bool {
-	validTokens := strings.Fields(secrets.ENV.ValidTokens)
-
-	givenToken := r.Header.Get("Authorization")
-	authorized := false
-	for _, token := range validTokens {
-		if givenToken == fmt.Sprintf("Bearer %s", token) {
-			authorized = true
-			break
+// IsAuthorized checks that the request has a bearer token with the given scope and is within the
+// token's rate limit, responding with a 401, 403, or 429 if it isn't. Requests without a valid token
+// are rate limited by IP so that tokens can't be guessed without limit.
+func IsAuthorized(w http.ResponseWriter, r *http.Request, scope string) bool {
+	givenToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token, found := lookup(givenToken)
+	if !ok || !found {
+		if ratelimit.AllowIP(w, r) {
+			http.Error(w, "Invalid bearer auth token", http.StatusUnauthorized)
 		}
+		return false
 	}
 
-	if !authorized {
-		http.Error(w, "Invalid bearer auth token", http.StatusUnauthorized)
+	if name, ok := r.Context().Value(tokenNameKey{}).(*string); ok {
+		*name = token.Name
+	}
+	limit := ratelimit.DefaultLimit()
+	if token.RateLimit != 0 {
+		limit.PerMinute = token.RateLimit
+	}
+	if !ratelimit.Allow(w, r, "token:"+token.Name, limit) {
+		return false
+	}
+	if !token.Allows(scope) {
+		http.Error(
+			w,
+			fmt.Sprintf("Token is not allowed to access %s", scope),
+			http.StatusForbidden,
+		)
+		return false
 	}
-	return authorized
+	return true
+}
+
+type tokenNameKey struct{}
+
+// WithTokenName returns a copy of r that records the name of the token it is authorized with.
+// The returned function returns the name once the request has been handled, or an empty string
+// if the request wasn't authorized with a known token.
+func WithTokenName(r *http.Request) (*http.Request, func() string) {
+	var name string
+	r = r.WithContext(context.WithValue(r.Context(), tokenNameKey{}, &name))
+	return r, func() string { return name }
 }
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// tokenPrefix starts every minted token so that they are easy to recognize.
+	tokenPrefix = "lcp"
+	idLength    = 8
+	secretBytes = 32
+	saltBytes   = 16
+	hashScheme  = "sha256"
+)
+
+// MintToken creates a new token of the form lcp_<id>_<secret>. The id is stored in plaintext to
+// find the token's hash while only the hash of the secret is stored.
+func MintToken() (token string, id string, err error) {
+	idBytes := make([]byte, idLength)
+	_, err = rand.Read(idBytes)
+	if err != nil {
+		return "", "", fmt.Errorf("%w failed to generate token id", err)
+	}
+	secret := make([]byte, secretBytes)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", "", fmt.Errorf("%w failed to generate token secret", err)
+	}
+	id = hex.EncodeToString(idBytes)
+	return fmt.Sprintf("%s_%s_%s", tokenPrefix, id, hex.EncodeToString(secret)), id, nil
+}
+
+// ParseToken splits a minted token into its id and secret.
+func ParseToken(token string) (id string, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(token, tokenPrefix+"_")
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// HashSecret hashes a token's secret with a random salt, returning it in the form
+// sha256:<salt>:<hash> that is stored in the tokens file.
+func HashSecret(secret string) (string, error) {
+	salt := make([]byte, saltBytes)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", fmt.Errorf("%w failed to generate salt", err)
+	}
+	return fmt.Sprintf(
+		"%s:%s:%s",
+		hashScheme,
+		hex.EncodeToString(salt),
+		hex.EncodeToString(hash(salt, secret)),
+	), nil
+}
+
+// hashedSecret is a parsed hash created by HashSecret.
+type hashedSecret struct {
+	salt []byte
+	sum  []byte
+}
+
+func parseHash(value string) (hashedSecret, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 || parts[0] != hashScheme {
+		return hashedSecret{}, errors.New("hash must be in the form sha256:<salt>:<hash>")
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return hashedSecret{}, fmt.Errorf("%w failed to decode salt", err)
+	}
+	sum, err := hex.DecodeString(parts[2])
+	if err != nil || len(sum) != sha256.Size {
+		return hashedSecret{}, errors.New("hash is not a hex encoded sha256 sum")
+	}
+	return hashedSecret{salt: salt, sum: sum}, nil
+}
+
+// matches reports if secret has the hash in constant time.
+func (h hashedSecret) matches(secret string) bool {
+	return subtle.ConstantTimeCompare(hash(h.salt, secret), h.sum) == 1
+}
+
+func hash(salt []byte, secret string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(secret))
+	return h.Sum(nil)
+}
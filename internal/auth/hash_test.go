@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		id, secret string
+		ok         bool
+	}{
+		{name: "minted", token: "lcp_3f9a2c1b_abcdef", id: "3f9a2c1b", secret: "abcdef", ok: true},
+		{name: "wrong prefix", token: "abc_3f9a2c1b_abcdef"},
+		{name: "missing secret", token: "lcp_3f9a2c1b"},
+		{name: "empty id", token: "lcp__abcdef"},
+		{name: "empty secret", token: "lcp_3f9a2c1b_"},
+		{name: "plaintext", token: "some-legacy-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, secret, ok := ParseToken(tt.token)
+			if ok != tt.ok || id != tt.id || secret != tt.secret {
+				t.Fatalf(
+					"got (%q, %q, %t), want (%q, %q, %t)",
+					id, secret, ok, tt.id, tt.secret, tt.ok,
+				)
+			}
+		})
+	}
+}
+
+func TestMintAndHash(t *testing.T) {
+	token, id, err := MintToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedID, secret, ok := ParseToken(token)
+	if !ok || parsedID != id {
+		t.Fatalf("failed to parse minted token %s", token)
+	}
+	hashed, err := HashSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := parseHash(hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		want   bool
+	}{
+		{name: "same secret", secret: secret, want: true},
+		{name: "wrong secret", secret: secret[:len(secret)-1] + "0"},
+		{name: "whole token", secret: token},
+		{name: "empty", secret: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.matches(tt.secret); got != tt.want {
+				t.Fatalf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHash(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "valid", value: "sha256:0011:" + sum},
+		{name: "wrong scheme", value: "md5:0011:" + sum, wantErr: true},
+		{name: "missing salt", value: "sha256:" + sum, wantErr: true},
+		{name: "extra part", value: "sha256:0011:" + sum + ":00", wantErr: true},
+		{name: "salt not hex", value: "sha256:zz:" + sum, wantErr: true},
+		{name: "sum not hex", value: "sha256:0011:" + strings.Repeat("zz", 32), wantErr: true},
+		{name: "short sum", value: "sha256:0011:abcd", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseHash(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
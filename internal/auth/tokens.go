@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/timber"
+	"gopkg.in/yaml.v3"
+)
+
+// Scopes that aren't the name of a cache. A token with the name of a cache as a scope can read
+// that cache's endpoints.
+const (
+	// ScopeAll grants access to every endpoint.
+	ScopeAll = "*"
+	// ScopeAdmin grants access to endpoints that change the state of a cache.
+	ScopeAdmin = "admin"
+	// ScopeStatus grants access to the /status endpoint.
+	ScopeStatus = "status"
+	// ScopeMetrics grants access to the /metrics endpoint.
+	ScopeMetrics = "metrics"
+)
+
+// Token is a named bearer token that is limited to a set of scopes. Only the token's id and the
+// salted hash of its secret are stored (see MintToken and HashSecret).
+type Token struct {
+	Name   string   `yaml:"name"`
+	ID     string   `yaml:"id"`
+	Hash   string   `yaml:"hash"`
+	Scopes []string `yaml:"scopes"`
+	// RateLimit is how many requests per minute the token can make, overriding RATE_LIMIT.
+	RateLimit int `yaml:"rate_limit,omitempty"`
+
+	hash hashedSecret
+	// legacy is set for plaintext tokens from VALID_TOKENS, which can only read caches.
+	legacy bool
+}
+
+// privilegedScopes are the scopes that aren't the name of a cache.
+var privilegedScopes = []string{ScopeAdmin, ScopeStatus, ScopeMetrics}
+
+// Allows reports if the token has the given scope.
+func (t Token) Allows(scope string) bool {
+	if t.legacy {
+		return !slices.Contains(privilegedScopes, scope)
+	}
+	return slices.Contains(t.Scopes, ScopeAll) || slices.Contains(t.Scopes, scope)
+}
+
+type tokensFile struct {
+	Tokens []Token `yaml:"tokens"`
+}
+
+var (
+	tokensMutex sync.RWMutex
+	tokens      []Token
+)
+
+// Load reads the tokens file at TOKENS_FILE, which looks like:
+//
+//	tokens:
+//	  - name: website
+//	    id: 3f9a2c1b7d4e8a60
+//	    hash: sha256:<salt>:<hash>
+//	    scopes: [github, steam, applemusic]
+//	    rate_limit: 600
+//	  - name: admin
+//	    id: 9b1e0d47c2a35f18
+//	    hash: sha256:<salt>:<hash>
+//	    scopes: ["*"]
+//
+// Entries are created with scripts/tokens. Plaintext tokens in VALID_TOKENS are still accepted so
+// that existing clients keep working, but they are hashed as soon as they are loaded and can only
+// read caches. The admin, status, and metrics scopes require a token from the tokens file.
+func Load() error {
+	loaded, err := loadTokens()
+	if err != nil {
+		return err
+	}
+	tokensMutex.Lock()
+	tokens = loaded
+	tokensMutex.Unlock()
+	timber.Done("loaded", len(loaded), "api tokens")
+	return nil
+}
+
+// Watch reloads the tokens whenever the secrets or the tokens file change until ctx is cancelled
+// so that tokens can be added or revoked without a restart. The current tokens are kept if the
+// tokens file is invalid.
+func Watch(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-secrets.Changed():
+			err := Load()
+			if err != nil {
+				timber.Error(err, "failed to reload api tokens; keeping current tokens")
+			}
+		}
+	}
+}
+
+func loadTokens() ([]Token, error) {
+	var loaded []Token
+	if secrets.Get().TokensFile != "" {
+		b, err := os.ReadFile(secrets.Get().TokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w failed to read tokens file", err)
+		}
+		var file tokensFile
+		err = yaml.Unmarshal(b, &file)
+		if err != nil {
+			return nil, fmt.Errorf("%w failed to parse tokens file", err)
+		}
+		loaded = file.Tokens
+	}
+
+	var (
+		names = map[string]bool{}
+		ids   = map[string]bool{}
+	)
+	for i, token := range loaded {
+		if token.Name == "" {
+			return nil, errors.New("token is missing a name")
+		}
+		if names[token.Name] {
+			return nil, fmt.Errorf("token name \"%s\" is used more than once", token.Name)
+		}
+		names[token.Name] = true
+		if token.ID == "" {
+			return nil, fmt.Errorf("token \"%s\" is missing an id", token.Name)
+		}
+		if ids[token.ID] {
+			return nil, fmt.Errorf("token id \"%s\" is used more than once", token.ID)
+		}
+		ids[token.ID] = true
+		h, err := parseHash(token.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("%w for token \"%s\"", err, token.Name)
+		}
+		loaded[i].hash = h
+		if len(token.Scopes) == 0 {
+			return nil, fmt.Errorf("token \"%s\" has no scopes", token.Name)
+		}
+	}
+
+	legacy := strings.Fields(secrets.Get().ValidTokens)
+	if len(legacy) > 0 {
+		timber.Warning(
+			"VALID_TOKENS holds plaintext tokens; mint hashed tokens with scripts/tokens instead",
+		)
+	}
+	for i, value := range legacy {
+		hashed, err := HashSecret(value)
+		if err != nil {
+			return nil, err
+		}
+		h, err := parseHash(hashed)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, Token{
+			Name:   fmt.Sprintf("VALID_TOKENS[%d]", i),
+			hash:   h,
+			legacy: true,
+		})
+	}
+	return loaded, nil
+}
+
+// lookup finds the token with the given value. Minted tokens are found by their id while tokens
+// from VALID_TOKENS, which have no id, are each checked. Secrets are compared in constant time.
+func lookup(value string) (Token, bool) {
+	tokensMutex.RLock()
+	defer tokensMutex.RUnlock()
+	if id, secret, ok := ParseToken(value); ok {
+		for _, token := range tokens {
+			if token.ID == id && token.hash.matches(secret) {
+				return token, true
+			}
+		}
+	}
+	var (
+		match Token
+		found bool
+	)
+	for _, token := range tokens {
+		// every legacy token is checked so that the time taken doesn't reveal which one matched
+		if token.ID == "" && token.hash.matches(value) && !found {
+			match, found = token, true
+		}
+	}
+	return match, found
+}
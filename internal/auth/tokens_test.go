@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.mattglei.ch/lcp/internal/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLookup(t *testing.T) {
+	website, websiteToken := mintTestToken(t, "website", "github")
+	admin, _ := mintTestToken(t, "admin", ScopeAll)
+	_, websiteSecret, _ := ParseToken(websiteToken)
+	t.Setenv("VALID_TOKENS", "legacy-token")
+	loadTestTokens(t, website, admin)
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "minted token", value: websiteToken, want: "website"},
+		{name: "legacy token", value: "legacy-token", want: "VALID_TOKENS[0]"},
+		{
+			name:  "wrong secret",
+			value: fmt.Sprintf("lcp_%s_%s", website.ID, strings.Repeat("0", 64)),
+		},
+		{name: "wrong id", value: fmt.Sprintf("lcp_%s_%s", admin.ID, websiteSecret)},
+		{name: "unknown id", value: fmt.Sprintf("lcp_%s_%s", "0000000000000000", websiteSecret)},
+		{name: "secret alone", value: websiteSecret},
+		{name: "empty", value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, ok := lookup(tt.value)
+			if ok != (tt.want != "") || token.Name != tt.want {
+				t.Fatalf("got (%q, %t), want %q", token.Name, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTokens(t *testing.T) {
+	const hash = "sha256:0011:abababababababababababababababababababababababababababababababab"
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			file: `
+tokens:
+  - {name: website, id: "01", hash: "` + hash + `", scopes: [github]}
+  - {name: admin, id: "02", hash: "` + hash + `", scopes: ["*"]}`,
+		},
+		{
+			name: "duplicate names",
+			file: `
+tokens:
+  - {name: website, id: "01", hash: "` + hash + `", scopes: [github]}
+  - {name: website, id: "02", hash: "` + hash + `", scopes: [steam]}`,
+			wantErr: `token name "website" is used more than once`,
+		},
+		{
+			name: "duplicate ids",
+			file: `
+tokens:
+  - {name: website, id: "01", hash: "` + hash + `", scopes: [github]}
+  - {name: admin, id: "01", hash: "` + hash + `", scopes: ["*"]}`,
+			wantErr: `token id "01" is used more than once`,
+		},
+		{
+			name: "missing name",
+			file: `
+tokens:
+  - {id: "01", hash: "` + hash + `", scopes: [github]}`,
+			wantErr: "token is missing a name",
+		},
+		{
+			name: "missing id",
+			file: `
+tokens:
+  - {name: website, hash: "` + hash + `", scopes: [github]}`,
+			wantErr: `token "website" is missing an id`,
+		},
+		{
+			name: "malformed hash",
+			file: `
+tokens:
+  - {name: website, id: "01", hash: "sha256:abc", scopes: [github]}`,
+			wantErr: "hash must be in the form",
+		},
+		{
+			name: "no scopes",
+			file: `
+tokens:
+  - {name: website, id: "01", hash: "` + hash + `"}`,
+			wantErr: `token "website" has no scopes`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTokensFile(t, tt.file)
+			_, err := loadTokens()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		name  string
+		token Token
+		scope string
+		want  bool
+	}{
+		{name: "scope", token: Token{Scopes: []string{"github"}}, scope: "github", want: true},
+		{name: "other scope", token: Token{Scopes: []string{"github"}}, scope: "steam"},
+		{name: "all", token: Token{Scopes: []string{ScopeAll}}, scope: ScopeAdmin, want: true},
+		{name: "legacy cache", token: Token{legacy: true}, scope: "github", want: true},
+		{name: "legacy admin", token: Token{legacy: true}, scope: ScopeAdmin},
+		{name: "legacy status", token: Token{legacy: true}, scope: ScopeStatus},
+		{name: "legacy metrics", token: Token{legacy: true}, scope: ScopeMetrics},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Allows(tt.scope); got != tt.want {
+				t.Fatalf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// mintTestToken mints a token with the given scopes, returning its entry in the tokens file and
+// the token that clients send.
+func mintTestToken(t *testing.T, name string, scopes ...string) (Token, string) {
+	t.Helper()
+	token, id, err := MintToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, secret, _ := ParseToken(token)
+	hash, err := HashSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Token{Name: name, ID: id, Hash: hash, Scopes: scopes}, token
+}
+
+// loadTestTokens writes entries to a tokens file and loads it, restoring the previous tokens when
+// the test finishes.
+func loadTestTokens(t *testing.T, entries ...Token) {
+	t.Helper()
+	file, err := yaml.Marshal(tokensFile{Tokens: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTokensFile(t, string(file))
+
+	tokensMutex.RLock()
+	previous := tokens
+	tokensMutex.RUnlock()
+	t.Cleanup(func() {
+		tokensMutex.Lock()
+		tokens = previous
+		tokensMutex.Unlock()
+	})
+	err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setTokensFile writes file to TOKENS_FILE and reloads the secrets.
+func setTokensFile(t *testing.T, file string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	err := os.WriteFile(path, []byte(file), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TOKENS_FILE", path)
+	t.Setenv("CONFIG_FILE", "")
+	secrets.Load()
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mattglei.ch/lcp/internal/apis"
+	"go.mattglei.ch/lcp/internal/auth"
+	"go.mattglei.ch/lcp/internal/cluster"
+	"go.mattglei.ch/lcp/internal/metrics"
+	"go.mattglei.ch/lcp/internal/tracing"
+	"go.mattglei.ch/timber"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// errNoRefresh is returned when a cache is refreshed before its update function has been set.
+	errNoRefresh = errors.New("cache has no update function to refresh with")
+	// errNotLeader is returned when a cache is refreshed on a follower, which receives its data
+	// from the leader instead of fetching it.
+	errNotLeader = errors.New("only the cluster leader can refresh caches")
+)
+
+// SetRefresh sets the function used to fetch fresh data when the cache is refreshed through the
+// admin API. Providers are refreshed with their fetch function.
+func (c *Cache[T]) SetRefresh(refresh func(context.Context) (T, error)) {
+	c.Mutex.Lock()
+	c.refresh = refresh
+	c.Mutex.Unlock()
+}
+
+// Name is the name of the provider that the cache holds the data of.
+func (c *Cache[T]) Name() string {
+	return c.name
+}
+
+// forceRefresh fetches fresh data for the cache right away, regardless of when the next update is
+// scheduled.
+func (c *Cache[T]) forceRefresh(ctx context.Context) error {
+	if !cluster.IsLeader() {
+		return errNotLeader
+	}
+	c.Mutex.RLock()
+	refresh := c.refresh
+	c.Mutex.RUnlock()
+	if refresh == nil {
+		return errNoRefresh
+	}
+
+	ctx, span := tracing.Start(
+		ctx,
+		c.name+".refresh",
+		attribute.String("cache", c.name),
+	)
+	start := time.Now()
+	data, err := recovered(ctx, refresh)
+	tracing.End(span, err)
+	if err != nil {
+		outcome := metrics.Error
+		if errors.Is(err, apis.ErrWarning) {
+			outcome = metrics.Warning
+		}
+		metrics.ObserveCacheUpdate(c.name, outcome, start)
+		c.health.failed(err)
+		return fmt.Errorf("%w refreshing %s cache failed", err, c.logPrefix())
+	}
+	metrics.ObserveCacheUpdate(c.name, metrics.Success, start)
+	c.Update(data)
+	return nil
+}
+
+// reset empties the cache so that it is filled again by the next update. The data being cleared is
+// kept in the cache's history.
+func (c *Cache[T]) reset() {
+	var empty T
+	payload := c.set(empty, time.Now().UTC())
+	err := cluster.Publish(context.Background(), c.name, payload)
+	if err != nil {
+		timber.Error(err, "failed to publish", c.logPrefix(), "cache clear")
+	}
+}
+
+// raw encodes everything held by the cache, including its history and data that is redacted from
+// public requests.
+func (c *Cache[T]) raw() ([]byte, error) {
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+	bin, err := json.Marshal(persistedCache[T]{
+		Data:    c.Data,
+		Updated: c.Updated,
+		History: c.history,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to json marshal raw cache", err)
+	}
+	return bin, nil
+}
+
+// lookup returns the registered cache with the given name.
+func lookup(name string) (registered, bool) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	for _, c := range registry {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// RegisterAdminRoutes adds the endpoints used to manually control caches to mux. Every endpoint
+// requires a token with the admin scope.
+func RegisterAdminRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /admin/{cache}/refresh", adminRoute(serveRefresh))
+	mux.HandleFunc("POST /admin/{cache}/clear", adminRoute(serveClear))
+	mux.HandleFunc("GET /admin/{cache}/raw", adminRoute(serveRaw))
+}
+
+// adminRoute authorizes an admin request and looks up the cache named in its path before calling
+// handler.
+func adminRoute(
+	handler func(w http.ResponseWriter, r *http.Request, c registered),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !auth.IsAuthorized(w, r, auth.ScopeAdmin) {
+			return
+		}
+		name := r.PathValue("cache")
+		c, ok := lookup(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("no cache named %s", name), http.StatusNotFound)
+			return
+		}
+		handler(w, r, c)
+	}
+}
+
+// serveRefresh updates the cache immediately and responds with its status once the update is done.
+func serveRefresh(w http.ResponseWriter, r *http.Request, c registered) {
+	if !beginUpdate() {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer endUpdate()
+	// the refresh is finished even if the client disconnects so the result isn't thrown away
+	ctx, cancel := detach(r.Context())
+	defer cancel()
+
+	timber.Info(fmt.Sprintf("[%s]", c.Name()), "manually refreshing cache")
+	err := c.forceRefresh(ctx)
+	if errors.Is(err, errNoRefresh) || errors.Is(err, errNotLeader) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		timber.Error(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeStatus(w, c.Status())
+}
+
+// serveClear empties the cache and responds with its status.
+func serveClear(w http.ResponseWriter, r *http.Request, c registered) {
+	timber.Warning(fmt.Sprintf("[%s]", c.Name()), "manually clearing cache")
+	c.reset()
+	writeStatus(w, c.Status())
+}
+
+// serveRaw responds with everything held by the cache, including its history and unredacted data.
+func serveRaw(w http.ResponseWriter, r *http.Request, c registered) {
+	bin, err := c.raw()
+	if err != nil {
+		timber.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_, err = w.Write(bin)
+	if err != nil {
+		timber.Error(err, "failed to write raw cache to request")
+	}
+}
+
+func writeStatus(w http.ResponseWriter, status Status) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(status)
+	if err != nil {
+		timber.Error(err, "failed to write json status to request")
+	}
+}
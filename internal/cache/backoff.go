@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBackoff is the longest that an updater will wait between attempts while failing.
+	maxBackoff = time.Hour
+	// backoffJitter is the fraction of a backoff that is randomly added or removed so that caches
+	// that started failing together don't retry in lockstep.
+	backoffJitter = 0.2
+)
+
+// BackoffState is the current state of a cache's periodic updater.
+type BackoffState struct {
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	Delay               time.Duration `json:"delay"`
+	NextUpdate          time.Time     `json:"next_update"`
+}
+
+type backoff struct {
+	mutex sync.RWMutex
+	state BackoffState
+}
+
+// schedule records when the next update will happen.
+func (b *backoff) schedule(failures int, delay time.Duration) {
+	b.mutex.Lock()
+	b.state = BackoffState{
+		ConsecutiveFailures: failures,
+		Delay:               delay,
+		NextUpdate:          time.Now().Add(delay).UTC(),
+	}
+	b.mutex.Unlock()
+}
+
+// Backoff returns the current state of the cache's periodic updater.
+func (c *Cache[T]) Backoff() BackoffState {
+	c.backoff.mutex.RLock()
+	defer c.backoff.mutex.RUnlock()
+	return c.backoff.state
+}
+
+// backoffDelay returns how long to wait before the next attempt after the given number of
+// consecutive failures. The delay doubles with every failure up to maxBackoff (or interval if that
+// is longer) and has jitter applied, without the jitter taking it past the limit.
+func backoffDelay(interval time.Duration, failures int) time.Duration {
+	if failures == 0 {
+		return interval
+	}
+	limit := max(interval, maxBackoff)
+	delay := interval
+	for range failures {
+		delay *= 2
+		if delay >= limit {
+			delay = limit
+			break
+		}
+	}
+	jitter := (rand.Float64()*2 - 1) * backoffJitter * float64(delay)
+	return min(delay+time.Duration(jitter), limit)
+}
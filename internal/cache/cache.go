@@ -1,132 +1,328 @@
 package cache
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"path/filepath"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
 
 	"go.mattglei.ch/lcp/internal/apis"
 	"go.mattglei.ch/lcp/internal/auth"
-	"go.mattglei.ch/lcp/internal/secrets"
-	"go.mattglei.ch/lcp/pkg/lcp"
+	"go.mattglei.ch/lcp/internal/cluster"
+	"go.mattglei.ch/lcp/internal/config"
+	"go.mattglei.ch/lcp/internal/metrics"
+	"go.mattglei.ch/lcp/internal/ratelimit"
+	"go.mattglei.ch/lcp/internal/tracing"
 	"go.mattglei.ch/timber"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-type CacheInstance int
+// Cache holds the data of a provider and serves it to clients. The cache is named after the
+// provider, which is used for its endpoints, storage, and token scope.
+type Cache[T any] struct {
+	name string
 
-const (
-	AppleMusic CacheInstance = iota
-	Workouts
-	GitHub
-	Steam
-)
+	Mutex   sync.RWMutex
+	Data    T
+	Updated time.Time
+	payload []byte // json encoded CacheResponse served to clients
+	etag    string
+	history []CacheResponse[T]
 
-func (c CacheInstance) String() string {
-	switch c {
-	case AppleMusic:
-		return "applemusic"
-	case Workouts:
-		return "workouts"
-	case GitHub:
-		return "github"
-	case Steam:
-		return "steam"
-	}
-	return "unknown"
-}
+	redact        func(T) T
+	publicPayload []byte // json encoded CacheResponse with redacted data served to public requests
+	publicETag    string
 
-func (c CacheInstance) LogPrefix() string {
-	return fmt.Sprintf("[%s]", c.String())
-}
+	refresh func(context.Context) (T, error) // fetches fresh data for the admin API
 
-type Cache[T lcp.CacheData] struct {
-	instance CacheInstance
-	filePath string
+	subscribersMutex sync.Mutex
+	subscribers      map[chan CacheResponse[T]]struct{}
 
-	Mutex   sync.RWMutex
-	Data    T
-	Updated time.Time
+	backoff backoff
+	health  health
 }
 
-func New[T lcp.CacheData](instance CacheInstance, data T, update bool) *Cache[T] {
+func New[T any](name string, data T, update bool) *Cache[T] {
 	cache := Cache[T]{
-		instance: instance,
-		Updated:  time.Now().UTC(),
-		filePath: filepath.Join(secrets.ENV.CacheFolder, fmt.Sprintf("%s.json", instance.String())),
+		name:    name,
+		Updated: time.Now().UTC(),
+	}
+	cache.load()
+	err := cache.encode()
+	if err != nil {
+		timber.Error(err, "failed to encode", cache.logPrefix(), "cache")
 	}
-	cache.loadFromFile()
 	if update {
 		cache.Update(data)
 	}
+	if cluster.Enabled() {
+		if cluster.IsLeader() {
+			cache.share()
+		}
+		go cache.follow()
+	}
+	register(&cache)
 	return &cache
 }
 
+func (c *Cache[T]) logPrefix() string {
+	return fmt.Sprintf("[%s]", c.name)
+}
+
+// RegisterRoutes adds the endpoints for the cache to mux.
+func (c *Cache[T]) RegisterRoutes(mux *http.ServeMux) {
+	name := c.name
+	mux.HandleFunc(fmt.Sprintf("GET /%s", name), c.observe("data", c.ServeHTTP))
+	mux.HandleFunc(fmt.Sprintf("GET /%s/stream", name), c.observe("stream", c.ServeStream))
+	mux.HandleFunc(fmt.Sprintf("GET /%s/history", name), c.observe("history", c.ServeHistory))
+	mux.HandleFunc(fmt.Sprintf("GET /%s/diff", name), c.observe("diff", c.ServeDiff))
+	if c.public() {
+		mux.HandleFunc(fmt.Sprintf("OPTIONS /%s", name), c.ServePreflight)
+	}
+}
+
+// observe records every response served by handler in the cache's request metrics.
+func (c *Cache[T]) observe(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := metrics.NewRecorder(w)
+		handler(rec, r)
+		metrics.ObserveCacheRequest(c.name, endpoint, rec.Status, rec.Bytes)
+	}
+}
+
 type CacheResponse[T any] struct {
 	Data    T         `json:"data"`
 	Updated time.Time `json:"updated"`
 }
 
+// ServeHTTP responds with the cache's data. Public caches can be read without a token, in which
+// case the redacted data is served and the request is rate limited by IP.
 func (c *Cache[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if !auth.IsAuthorized(w, r) {
+	anonymous := c.public() && r.Header.Get("Authorization") == ""
+	if c.public() {
+		setCORSHeaders(w, r)
+	}
+	if anonymous {
+		if !ratelimit.AllowIP(w, r) {
+			return
+		}
+	} else if !auth.IsAuthorized(w, r, c.name) {
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
+
 	c.Mutex.RLock()
-	err := json.NewEncoder(w).Encode(CacheResponse[T]{Data: c.Data, Updated: c.Updated})
-	c.Mutex.RUnlock()
-	if err != nil {
-		err = fmt.Errorf("%w failed to write json data to request", err)
-		timber.Error(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	payload, etag, updated := c.payload, c.etag, c.Updated
+	if anonymous {
+		payload, etag = c.publicPayload, c.publicETag
 	}
+	c.Mutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", etag)
+	// ServeContent handles the If-None-Match and If-Modified-Since conditional requests, responding
+	// with a 304 if the client already has the current data.
+	http.ServeContent(w, r, "", updated, bytes.NewReader(payload))
 }
 
 func (c *Cache[T]) Update(data T) {
 	c.Mutex.RLock()
 	oldBin, err := json.Marshal(c.Data)
+	c.Mutex.RUnlock()
 	if err != nil {
 		timber.Error(err, "failed to json marshal old data")
 		return
 	}
-	c.Mutex.RUnlock()
 	newBin, err := json.Marshal(data)
 	if err != nil {
 		timber.Error(err, "failed to json marshal new data")
 		return
 	}
 
+	c.health.succeeded()
 	new := string(newBin)
 	if string(oldBin) != new && new != "null" && strings.Trim(new, " ") != "" {
-		c.Mutex.Lock()
-		c.Data = data
-		c.Updated = time.Now().UTC()
-		c.Mutex.Unlock()
+		payload := c.set(data, time.Now().UTC())
+		err = cluster.Publish(context.Background(), c.name, payload)
+		if err != nil {
+			timber.Error(err, "failed to publish", c.logPrefix(), "cache update")
+		}
+	}
+}
+
+// share publishes the current data so that followers that start before it next changes can load
+// it, as Update only publishes changes.
+func (c *Cache[T]) share() {
+	c.Mutex.RLock()
+	data, err := json.Marshal(c.Data)
+	payload := c.payload
+	c.Mutex.RUnlock()
+	if err != nil || isZero[T](data) {
+		return
+	}
+	err = cluster.Publish(context.Background(), c.name, payload)
+	if err != nil {
+		timber.Error(err, "failed to publish", c.logPrefix(), "cache data")
+	}
+}
+
+// set swaps in new data, recording the previous data in the cache's history, and then persists and
+// broadcasts it. The encoded payload is returned.
+func (c *Cache[T]) set(data T, updated time.Time) []byte {
+	return c.swap(data, updated, true)
+}
+
+// swap is set with persisting being optional so that followers don't write every update from the
+// leader to storage that the leader already saves it to.
+func (c *Cache[T]) swap(data T, updated time.Time, persist bool) []byte {
+	c.Mutex.Lock()
+	oldBin, err := json.Marshal(c.Data)
+	if err == nil && !isZero[T](oldBin) {
+		c.record(CacheResponse[T]{Data: c.Data, Updated: c.Updated})
+	}
+	c.Data = data
+	c.Updated = updated
+	err = c.encode()
+	event := CacheResponse[T]{Data: c.Data, Updated: c.Updated}
+	payload := c.payload
+	c.Mutex.Unlock()
+	if err != nil {
+		timber.Error(err, "failed to encode", c.logPrefix(), "cache")
+	}
+
+	if persist {
+		c.persist()
+	}
+	c.broadcast(event)
+	timber.Done(c.logPrefix(), "cache updated")
+	return payload
+}
 
-		c.persistToFile()
-		timber.Done(c.instance.LogPrefix(), "cache updated")
+// encode marshals the current data into the payload that is served to clients and derives a strong
+// ETag from it so that it only has to be computed once per update. The write lock must be held.
+func (c *Cache[T]) encode() error {
+	bin, err := json.Marshal(CacheResponse[T]{Data: c.Data, Updated: c.Updated})
+	if err != nil {
+		return fmt.Errorf("%w failed to json marshal cache response", err)
 	}
+	c.payload = bin
+	c.etag = fmt.Sprintf(`"%x"`, sha256.Sum256(bin))
+	return c.encodePublic()
+}
+
+// recovered calls update, turning a panic into an error so that a provider that panics is backed
+// off like any other failed update instead of crashing the server.
+func recovered[T any](
+	ctx context.Context,
+	update func(context.Context) (T, error),
+) (data T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("update panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return update(ctx)
 }
 
-func UpdatePeriodically[T lcp.CacheData, C any](
+// UpdatePeriodically updates the cache every interval until ctx is cancelled. interval is called
+// before every update and whenever the config is reloaded so that it can be changed while running.
+// Consecutive failures back off exponentially, with the current state available from Backoff, and
+// reset on the next success. Updates that are rate limited are paused until the rate limit window
+// resets.
+func UpdatePeriodically[T any](
+	ctx context.Context,
 	cache *Cache[T],
-	client C,
-	update func(C) (T, error),
-	interval time.Duration,
+	update func(context.Context) (T, error),
+	interval func() time.Duration,
 ) {
+	var (
+		failures int
+		delay    = interval()
+		timer    = time.NewTimer(delay)
+	)
+	defer timer.Stop()
 	for {
-		time.Sleep(interval)
-		data, err := update(client)
+		cache.backoff.schedule(failures, delay)
+		timer.Reset(delay)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-config.Changed():
+				// a changed interval takes effect right away instead of after the current wait
+				if failures == 0 && interval() != delay {
+					delay = interval()
+					cache.backoff.schedule(failures, delay)
+					timer.Reset(delay)
+				}
+			case <-timer.C:
+				break wait
+			}
+		}
+		if !cluster.IsLeader() {
+			failures, delay = 0, interval()
+			continue
+		}
+		if !beginUpdate() {
+			return
+		}
+		work, cancel := detach(ctx)
+		work, span := tracing.Start(
+			work,
+			cache.name+".update",
+			attribute.String("cache", cache.name),
+			attribute.Int("cache.consecutive_failures", failures),
+		)
+		start := time.Now()
+		data, err := recovered(work, update)
+		tracing.End(span, err)
+		cancel()
 		if err != nil {
-			if !errors.Is(err, apis.ErrWarning) && !errors.Is(err, ErrAppleMusicNoArtwork) {
-				timber.Error(err, "updating", cache.instance.LogPrefix(), "cache failed")
+			if !errors.Is(err, apis.ErrWarning) {
+				timber.Error(err, "updating", cache.logPrefix(), "cache failed")
+				metrics.ObserveCacheUpdate(cache.name, metrics.Error, start)
+			} else {
+				metrics.ObserveCacheUpdate(cache.name, metrics.Warning, start)
+			}
+			cache.health.failed(err)
+			failures++
+			delay = backoffDelay(interval(), failures)
+			var upstreamErr *apis.UpstreamError
+			if errors.As(err, &upstreamErr) && upstreamErr.RateLimited() &&
+				time.Until(upstreamErr.RateLimitedUntil) > delay {
+				delay = time.Until(upstreamErr.RateLimitedUntil)
+				timber.Warning(
+					cache.logPrefix(),
+					"pausing updates until",
+					upstreamErr.RateLimitedUntil.Format(time.Kitchen),
+					"after being rate limited by",
+					upstreamErr.Host,
+				)
+			}
+			if failures > 1 {
+				timber.Warning(
+					cache.logPrefix(),
+					failures,
+					"consecutive failed updates; backing off for",
+					delay.Round(time.Second),
+				)
 			}
 		} else {
+			if failures > 1 {
+				timber.Done(cache.logPrefix(), "recovered after", failures, "failures")
+			}
+			metrics.ObserveCacheUpdate(cache.name, metrics.Success, start)
+			failures, delay = 0, interval()
 			cache.Update(data)
 		}
+		endUpdate()
 	}
 }
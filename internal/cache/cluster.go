@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.mattglei.ch/lcp/internal/cluster"
+	"go.mattglei.ch/timber"
+)
+
+// follow applies every update for the cache that is published by the leader of the cluster. This
+// lets followers serve fresh data without ever calling upstream APIs.
+func (c *Cache[T]) follow() {
+	ctx := context.Background()
+	payloads, err := cluster.Subscribe(ctx, c.name)
+	if err != nil {
+		timber.Error(err, "failed to follow", c.logPrefix(), "cache updates")
+		return
+	}
+
+	// the leader only publishes when its data changes, so the latest data is loaded after
+	// subscribing to not miss an update that is published in between
+	latest, err := cluster.Latest(ctx, c.name)
+	if err != nil {
+		timber.Error(err, "failed to load latest", c.logPrefix(), "data from leader")
+	} else if latest != nil {
+		c.apply(latest)
+	}
+
+	for payload := range payloads {
+		c.apply(payload)
+	}
+}
+
+// apply swaps in data published by the leader if it is newer than the current data.
+func (c *Cache[T]) apply(payload []byte) {
+	var resp CacheResponse[T]
+	err := json.Unmarshal(payload, &resp)
+	if err != nil {
+		timber.Error(err, "failed to decode", c.logPrefix(), "update from leader")
+		return
+	}
+
+	c.health.succeeded()
+	c.Mutex.RLock()
+	current := c.Updated
+	c.Mutex.RUnlock()
+	if !resp.Updated.After(current) {
+		return
+	}
+	c.swap(resp.Data, resp.Updated, !sharedStorage())
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// ListDiff describes the differences found at a single location in a cache's data.
+type ListDiff struct {
+	Added   []json.RawMessage `json:"added,omitempty"`
+	Removed []json.RawMessage `json:"removed,omitempty"`
+	Changed []Change          `json:"changed,omitempty"`
+}
+
+// Change is a value that was modified in place.
+type Change struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// keyFields are the json fields, in order of preference, used to identify the same item across two
+// snapshots of a list.
+var keyFields = []string{"id", "app_id", "name"}
+
+// diff compares the json representation of before and after, returning the differences keyed by
+// the path at which they were found (e.g. data.playlists[p.AWXoZoxHLrvpJlY].tracks). Lists are
+// compared by the identity of their items so that reordering alone is not reported.
+func diff[T any](before, after T) (map[string]*ListDiff, error) {
+	beforeValue, err := toJSONValue(before)
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to convert old data to json value", err)
+	}
+	afterValue, err := toJSONValue(after)
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to convert new data to json value", err)
+	}
+
+	changes := map[string]*ListDiff{}
+	diffValues("data", beforeValue, afterValue, changes)
+	return changes, nil
+}
+
+func toJSONValue(v any) (any, error) {
+	bin, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// numbers are kept as json.Number so that large ids are used as keys without being rounded
+	decoder := json.NewDecoder(bytes.NewReader(bin))
+	decoder.UseNumber()
+	var value any
+	err = decoder.Decode(&value)
+	return value, err
+}
+
+func diffValues(path string, before, after any, changes map[string]*ListDiff) {
+	if equal(before, after) {
+		return
+	}
+
+	// a nil slice is encoded as null so it is treated the same as an empty list
+	beforeList, beforeIsList := before.([]any)
+	afterList, afterIsList := after.([]any)
+	if (beforeIsList || before == nil) && (afterIsList || after == nil) {
+		diffLists(path, beforeList, afterList, changes)
+		return
+	}
+
+	beforeObject, beforeIsObject := before.(map[string]any)
+	afterObject, afterIsObject := after.(map[string]any)
+	if beforeIsObject && afterIsObject {
+		keys := slices.Sorted(maps.Keys(beforeObject))
+		for key := range afterObject {
+			if _, ok := beforeObject[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			diffValues(path+"."+key, beforeObject[key], afterObject[key], changes)
+		}
+		return
+	}
+
+	d := entry(path, changes)
+	d.Changed = append(d.Changed, Change{Before: raw(before), After: raw(after)})
+}
+
+func diffLists(path string, before, after []any, changes map[string]*ListDiff) {
+	beforeKeys, beforeItems := indexList(before)
+	afterKeys, afterItems := indexList(after)
+
+	for _, key := range beforeKeys {
+		if _, ok := afterItems[key]; !ok {
+			d := entry(path, changes)
+			d.Removed = append(d.Removed, raw(beforeItems[key]))
+		}
+	}
+	for _, key := range afterKeys {
+		beforeItem, ok := beforeItems[key]
+		if !ok {
+			d := entry(path, changes)
+			d.Added = append(d.Added, raw(afterItems[key]))
+			continue
+		}
+		diffValues(fmt.Sprintf("%s[%s]", path, key), beforeItem, afterItems[key], changes)
+	}
+}
+
+// indexList keys every item in a list by its identity, returning the keys in their original order.
+// Items that appear more than once get a numbered suffix so that duplicates are still compared.
+func indexList(list []any) ([]string, map[string]any) {
+	var (
+		keys  = make([]string, 0, len(list))
+		items = make(map[string]any, len(list))
+		seen  = map[string]int{}
+	)
+	for _, item := range list {
+		key := itemKey(item)
+		seen[key]++
+		if seen[key] > 1 {
+			key = fmt.Sprintf("%s#%d", key, seen[key])
+		}
+		keys = append(keys, key)
+		items[key] = item
+	}
+	return keys, items
+}
+
+func itemKey(item any) string {
+	if object, ok := item.(map[string]any); ok {
+		for _, field := range keyFields {
+			if value, ok := object[field]; ok && value != nil && value != "" {
+				return fmt.Sprint(value)
+			}
+		}
+	}
+	return string(raw(item))
+}
+
+func entry(path string, changes map[string]*ListDiff) *ListDiff {
+	d, ok := changes[path]
+	if !ok {
+		d = &ListDiff{}
+		changes[path] = d
+	}
+	return d
+}
+
+func equal(a, b any) bool {
+	return string(raw(a)) == string(raw(b))
+}
+
+// raw encodes a decoded json value back into json. Object keys are sorted by encoding/json so the
+// result can be used for comparisons.
+func raw(value any) json.RawMessage {
+	bin, err := json.Marshal(value)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return bin
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+type diffSong struct {
+	ID    string `json:"id"`
+	Track string `json:"track"`
+	Plays int    `json:"plays"`
+}
+
+type diffData struct {
+	Name  string     `json:"name"`
+	Count int        `json:"count"`
+	Songs []diffSong `json:"songs"`
+}
+
+func TestDiff(t *testing.T) {
+	songs := []diffSong{{ID: "1", Track: "One", Plays: 1}, {ID: "2", Track: "Two", Plays: 2}}
+	tests := []struct {
+		name          string
+		before, after diffData
+		want          map[string]string
+	}{
+		{
+			name:   "unchanged",
+			before: diffData{Name: "a", Count: 1, Songs: songs},
+			after:  diffData{Name: "a", Count: 1, Songs: songs},
+			want:   map[string]string{},
+		},
+		{
+			name:   "changed scalars",
+			before: diffData{Name: "a", Count: 1},
+			after:  diffData{Name: "b", Count: 2},
+			want: map[string]string{
+				"data.name":  `{"changed":[{"before":"a","after":"b"}]}`,
+				"data.count": `{"changed":[{"before":1,"after":2}]}`,
+			},
+		},
+		{
+			name:   "added to list",
+			before: diffData{Songs: songs[:1]},
+			after:  diffData{Songs: songs},
+			want: map[string]string{
+				"data.songs": `{"added":[{"id":"2","plays":2,"track":"Two"}]}`,
+			},
+		},
+		{
+			name:   "removed from list",
+			before: diffData{Songs: songs},
+			after:  diffData{Songs: songs[1:]},
+			want: map[string]string{
+				"data.songs": `{"removed":[{"id":"1","plays":1,"track":"One"}]}`,
+			},
+		},
+		{
+			name:   "changed in list",
+			before: diffData{Songs: songs},
+			after: diffData{
+				Songs: []diffSong{{ID: "1", Track: "One", Plays: 5}, songs[1]},
+			},
+			want: map[string]string{
+				"data.songs[1].plays": `{"changed":[{"before":1,"after":5}]}`,
+			},
+		},
+		{
+			name:   "reordered list",
+			before: diffData{Songs: songs},
+			after:  diffData{Songs: []diffSong{songs[1], songs[0]}},
+			want:   map[string]string{},
+		},
+		{
+			name:   "nil list",
+			before: diffData{},
+			after:  diffData{Songs: songs[:1]},
+			want: map[string]string{
+				"data.songs": `{"added":[{"id":"1","plays":1,"track":"One"}]}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertChanges(t, changes, tt.want)
+		})
+	}
+}
+
+func TestDiffLists(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          map[string]string
+	}{
+		{
+			name:   "added scalars",
+			before: `[1, 2]`,
+			after:  `[1, 2, 3]`,
+			want:   map[string]string{"list": `{"added":[3]}`},
+		},
+		{
+			name:   "removed scalars",
+			before: `["a", "b"]`,
+			after:  `["b"]`,
+			want:   map[string]string{"list": `{"removed":["a"]}`},
+		},
+		{
+			// scalars are identified by their value so a changed scalar is removed and added
+			name:   "changed scalars",
+			before: `["a", "b"]`,
+			after:  `["a", "c"]`,
+			want:   map[string]string{"list": `{"added":["c"],"removed":["b"]}`},
+		},
+		{
+			name:   "added objects",
+			before: `[{"id": 1}]`,
+			after:  `[{"id": 1}, {"id": 2}]`,
+			want:   map[string]string{"list": `{"added":[{"id":2}]}`},
+		},
+		{
+			name:   "removed objects",
+			before: `[{"app_id": 1}, {"app_id": 2}]`,
+			after:  `[{"app_id": 2}]`,
+			want:   map[string]string{"list": `{"removed":[{"app_id":1}]}`},
+		},
+		{
+			name:   "changed objects",
+			before: `[{"name": "a", "plays": 1}, {"name": "b", "plays": 1}]`,
+			after:  `[{"name": "a", "plays": 2}, {"name": "b", "plays": 1}]`,
+			want: map[string]string{
+				"list[a].plays": `{"changed":[{"before":1,"after":2}]}`,
+			},
+		},
+		{
+			name:   "changed nested list",
+			before: `[{"id": "p", "tracks": ["x"]}]`,
+			after:  `[{"id": "p", "tracks": ["x", "y"]}]`,
+			want:   map[string]string{"list[p].tracks": `{"added":["y"]}`},
+		},
+		{
+			name:   "duplicates",
+			before: `[1, 1]`,
+			after:  `[1]`,
+			want:   map[string]string{"list": `{"removed":[1]}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := map[string]*ListDiff{}
+			diffLists("list", decodeList(t, tt.before), decodeList(t, tt.after), changes)
+			assertChanges(t, changes, tt.want)
+		})
+	}
+}
+
+func TestIndexList(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		want []string
+	}{
+		{name: "empty", list: `[]`, want: []string{}},
+		{name: "scalars", list: `[1, "a", true]`, want: []string{"1", `"a"`, "true"}},
+		{
+			name: "key fields",
+			list: `[{"id": 1, "name": "a"}, {"app_id": 2}, {"name": "b"}]`,
+			want: []string{"1", "2", "b"},
+		},
+		{
+			name: "empty key fields are skipped",
+			list: `[{"id": "", "name": "a"}, {"id": null, "app_id": 3}]`,
+			want: []string{"a", "3"},
+		},
+		{
+			name: "objects without a key field",
+			list: `[{"b": 1, "a": 2}]`,
+			want: []string{`{"a":2,"b":1}`},
+		},
+		{
+			// large ids are kept as numbers rather than being rounded
+			name: "large ids",
+			list: `[{"id": 12345678901234567890}]`,
+			want: []string{"12345678901234567890"},
+		},
+		{
+			name: "duplicates",
+			list: `[{"id": 1}, {"id": 1}, {"id": 2}, {"id": 1}]`,
+			want: []string{"1", "1#2", "2", "1#3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := decodeList(t, tt.list)
+			keys, items := indexList(list)
+			if !slices.Equal(keys, tt.want) {
+				t.Fatalf("got keys %q, want %q", keys, tt.want)
+			}
+			if len(items) != len(list) {
+				t.Fatalf("got %d items, want %d", len(items), len(list))
+			}
+			for i, key := range keys {
+				if !equal(items[key], list[i]) {
+					t.Errorf("got %s for key %s, want %s", raw(items[key]), key, raw(list[i]))
+				}
+			}
+		})
+	}
+}
+
+func decodeList(t *testing.T, s string) []any {
+	t.Helper()
+	value, err := toJSONValue(json.RawMessage(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := value.([]any)
+	return list
+}
+
+func assertChanges(t *testing.T, changes map[string]*ListDiff, want map[string]string) {
+	t.Helper()
+	got := map[string]string{}
+	for path, d := range changes {
+		bin, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[path] = string(bin)
+	}
+	for path, w := range want {
+		if got[path] != w {
+			t.Errorf("got %s at %s, want %s", got[path], path, w)
+		}
+	}
+	for path, g := range got {
+		if _, ok := want[path]; !ok {
+			t.Errorf("got unexpected %s at %s", g, path)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.mattglei.ch/lcp/internal/auth"
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/timber"
+)
+
+type DiffResponse struct {
+	From    time.Time            `json:"from"`
+	To      time.Time            `json:"to"`
+	Changes map[string]*ListDiff `json:"changes"`
+}
+
+// record adds the given snapshot to the cache's history, dropping the oldest snapshots once more
+// than CACHE_HISTORY_SIZE are stored. The write lock must be held.
+func (c *Cache[T]) record(snapshot CacheResponse[T]) {
+	size := secrets.Get().CacheHistorySize
+	if size <= 0 {
+		c.history = nil
+		return
+	}
+	c.history = append(c.history, snapshot)
+	if len(c.history) > size {
+		c.history = slices.Clone(c.history[len(c.history)-size:])
+	}
+}
+
+// isZero reports if bin is the json encoding of an empty value of T, which is what a cache holds
+// before its first successful update.
+func isZero[T any](bin []byte) bool {
+	var zero T
+	zeroBin, err := json.Marshal(zero)
+	return err == nil && string(bin) == string(zeroBin)
+}
+
+// snapshots returns the stored history followed by the current data, ordered from oldest to
+// newest.
+func (c *Cache[T]) snapshots() []CacheResponse[T] {
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+	snapshots := slices.Clone(c.history)
+	return append(snapshots, CacheResponse[T]{Data: c.Data, Updated: c.Updated})
+}
+
+// ServeHistory responds with the current data and every stored snapshot, newest first.
+func (c *Cache[T]) ServeHistory(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAuthorized(w, r, c.name) {
+		return
+	}
+	snapshots := c.snapshots()
+	slices.Reverse(snapshots)
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(snapshots)
+	if err != nil {
+		err = fmt.Errorf("%w failed to write json history to request", err)
+		timber.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ServeDiff responds with what was added, removed, or changed between the snapshot that was
+// current at the time given by the since query parameter and the current data. If since is older
+// than the oldest stored snapshot then the oldest snapshot is used instead.
+func (c *Cache[T]) ServeDiff(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAuthorized(w, r, c.name) {
+		return
+	}
+	since, err := parseSince(r.URL.Query().Get("since"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	snapshots := c.snapshots()
+	from := snapshots[0]
+	for _, snapshot := range snapshots {
+		if snapshot.Updated.After(since) {
+			break
+		}
+		from = snapshot
+	}
+	to := snapshots[len(snapshots)-1]
+
+	changes, err := diff(from.Data, to.Data)
+	if err != nil {
+		err = fmt.Errorf("%w failed to diff snapshots", err)
+		timber.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(DiffResponse{
+		From:    from.Updated,
+		To:      to.Updated,
+		Changes: changes,
+	})
+	if err != nil {
+		err = fmt.Errorf("%w failed to write json diff to request", err)
+		timber.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseSince parses a RFC 3339 timestamp or a unix timestamp in seconds.
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("missing since query parameter")
+	}
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return time.Unix(unix, 0).UTC(), nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(
+			"since must be a RFC 3339 or unix timestamp, got \"%s\"",
+			value,
+		)
+	}
+	return since, nil
+}
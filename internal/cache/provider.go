@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mattglei.ch/lcp/internal/auth"
+	"go.mattglei.ch/lcp/internal/cluster"
+	"go.mattglei.ch/timber"
+)
+
+// Provider is a source of data that is cached and served under its name. Providers add themselves
+// to the registry with Register, usually from an init function, so that adding a provider only
+// requires importing its package.
+type Provider[T any] interface {
+	// Name is used for the provider's endpoints, storage, token scope, and log prefix.
+	Name() string
+	// RequiredSecrets are the env vars that must be set for the provider to be enabled.
+	RequiredSecrets() []string
+	// Setup prepares the provider to fetch data, such as by creating the clients that it needs.
+	Setup(ctx context.Context, deps Deps) error
+	Fetch(ctx context.Context) (T, error)
+	// Interval is how long to wait between fetches. It is called before every update so that it
+	// can be changed while running. Providers that return zero are only updated by their routes or
+	// the admin API.
+	Interval() time.Duration
+	// Routes adds endpoints for the provider beyond the ones that every cache has.
+	Routes(mux *http.ServeMux, cache *Cache[T])
+}
+
+// Redactor is implemented by providers with data that needs to be redacted when their cache is
+// public (see SetRedaction).
+type Redactor[T any] interface {
+	Redact(data T) T
+}
+
+// Deps are shared by every provider.
+type Deps struct {
+	Mux    *http.ServeMux
+	Client *http.Client
+	Redis  *redis.Client
+}
+
+// Registered is a provider with the type of its data erased so that every provider can be set up
+// together.
+type Registered struct {
+	Name            string
+	RequiredSecrets []string
+	Start           func(ctx context.Context, deps Deps) error
+}
+
+var (
+	providers      []Registered
+	providersMutex sync.Mutex
+
+	// validName matches names that can be used as a single segment of an endpoint's path.
+	validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	// reservedNames are used by the server's own endpoints and the admin token scope.
+	reservedNames = []string{auth.ScopeAdmin, "healthz", "metrics", "readyz", "status"}
+)
+
+// Register adds a provider to the registry. It panics if the provider's name is invalid, reserved,
+// or already registered so that the mistake is caught on boot rather than partway through adding
+// routes.
+func Register[T any](p Provider[T]) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	name := p.Name()
+	if !validName.MatchString(name) {
+		panic(fmt.Sprintf("provider name %q must match %s", name, validName))
+	}
+	if slices.Contains(reservedNames, name) {
+		panic(fmt.Sprintf("provider name %s is reserved", name))
+	}
+	if slices.ContainsFunc(providers, func(r Registered) bool { return r.Name == name }) {
+		panic(fmt.Sprintf("provider %s is already registered", name))
+	}
+	providers = append(providers, Registered{
+		Name:            name,
+		RequiredSecrets: p.RequiredSecrets(),
+		Start: func(ctx context.Context, deps Deps) error {
+			return start(ctx, p, deps)
+		},
+	})
+}
+
+// Providers returns every registered provider, sorted by name.
+func Providers() []Registered {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	sorted := slices.Clone(providers)
+	slices.SortFunc(sorted, func(a, b Registered) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return sorted
+}
+
+// start sets up the provider, creates its cache with the initial data, registers its routes, and
+// starts updating it.
+func start[T any](ctx context.Context, p Provider[T], deps Deps) error {
+	name := p.Name()
+	err := p.Setup(ctx, deps)
+	if err != nil {
+		return fmt.Errorf("%w failed to setup %s provider", err, name)
+	}
+
+	// followers receive their data from the leader instead of fetching it
+	var (
+		leader = cluster.IsLeader()
+		data   T
+	)
+	if leader {
+		data, err = p.Fetch(ctx)
+		if err != nil {
+			timber.Error(err, "initial fetch of", name, "cache data failed")
+		}
+	}
+
+	cache := New(name, data, leader && err == nil)
+	if redactor, ok := p.(Redactor[T]); ok {
+		cache.SetRedaction(redactor.Redact)
+	}
+	cache.SetRefresh(p.Fetch)
+	cache.RegisterRoutes(deps.Mux)
+	p.Routes(deps.Mux, cache)
+	if p.Interval() != 0 {
+		go UpdatePeriodically(ctx, cache, p.Fetch, p.Interval)
+	}
+
+	timber.Done(cache.logPrefix(), "setup cache and endpoints")
+	return nil
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/timber"
+)
+
+// public reports if the cache is listed in PUBLIC_CACHES, meaning that its data can be read
+// without a token. Requests without a token receive the redacted data.
+func (c *Cache[T]) public() bool {
+	return slices.Contains(secrets.Get().PublicCaches, c.name)
+}
+
+// SetRedaction sets the function that removes sensitive fields from the data served to public
+// requests. redact must return a copy rather than modifying the data that it is given.
+func (c *Cache[T]) SetRedaction(redact func(T) T) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	c.redact = redact
+	err := c.encode()
+	if err != nil {
+		timber.Error(err, "failed to encode", c.logPrefix(), "cache")
+	}
+}
+
+// encodePublic encodes the redacted data served to public requests. The write lock must be held.
+func (c *Cache[T]) encodePublic() error {
+	if c.redact == nil {
+		c.publicPayload, c.publicETag = c.payload, c.etag
+		return nil
+	}
+	bin, err := json.Marshal(CacheResponse[T]{Data: c.redact(c.Data), Updated: c.Updated})
+	if err != nil {
+		return fmt.Errorf("%w failed to json marshal redacted cache response", err)
+	}
+	c.publicPayload = bin
+	c.publicETag = fmt.Sprintf(`"%x"`, sha256.Sum256(bin))
+	return nil
+}
+
+// ServePreflight responds to CORS preflight requests for a public cache.
+func (c *Cache[T]) ServePreflight(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, r)
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+	w.Header().Set("Access-Control-Max-Age", "86400")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setCORSHeaders allows the request's origin to read the response if it is in
+// CORS_ALLOWED_ORIGINS.
+func setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	// requests with a token get the unredacted data so shared caches must not serve one response
+	// for the other
+	w.Header().Add("Vary", "Origin")
+	w.Header().Add("Vary", "Authorization")
+	origin := r.Header.Get("Origin")
+	switch {
+	case slices.Contains(secrets.Get().CORSAllowedOrigins, "*"):
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	case origin != "" && slices.Contains(secrets.Get().CORSAllowedOrigins, origin):
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	default:
+		return
+	}
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"sync"
+
+	"go.mattglei.ch/lcp/internal/cluster"
+
+	"go.mattglei.ch/timber"
+)
+
+var (
+	// registry is every cache that has been created so that they can be flushed during shutdown and
+	// reported on by the status and admin endpoints.
+	registry      []registered
+	registryMutex sync.Mutex
+
+	inFlight      sync.WaitGroup
+	inFlightMutex sync.Mutex
+	stopping      bool
+
+	// forceStop cancels updates that are still running once Shutdown gives up waiting for them.
+	hardStop, forceStop = context.WithCancel(context.Background())
+)
+
+type registered interface {
+	persist()
+	Status() Status
+	Name() string
+	forceRefresh(ctx context.Context) error
+	reset()
+	raw() ([]byte, error)
+}
+
+func register(c registered) {
+	registryMutex.Lock()
+	registry = append(registry, c)
+	registryMutex.Unlock()
+}
+
+// beginUpdate marks the start of an update, returning false if shutdown has already started.
+func beginUpdate() bool {
+	inFlightMutex.Lock()
+	defer inFlightMutex.Unlock()
+	if stopping {
+		return false
+	}
+	inFlight.Add(1)
+	return true
+}
+
+func endUpdate() {
+	inFlight.Done()
+}
+
+// detach returns a context for an update that outlives ctx being cancelled so that updates that
+// are in progress when shutdown starts (such as strava map uploads) get to finish. It is cancelled
+// if Shutdown gives up waiting.
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	work, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(hardStop, cancel)
+	return work, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Track runs work, such as an update triggered by a webhook, as an update that Shutdown waits for.
+// work is given a context that outlives ctx being cancelled but is cancelled if Shutdown gives up
+// waiting. False is returned without running work if shutdown has already started.
+func Track(ctx context.Context, work func(ctx context.Context)) bool {
+	if !beginUpdate() {
+		return false
+	}
+	defer endUpdate()
+	ctx, cancel := detach(ctx)
+	defer cancel()
+	work(ctx)
+	return true
+}
+
+// Shutdown waits for updates that are in progress to finish, giving up and cancelling them once ctx
+// is done, and then persists every cache.
+func Shutdown(ctx context.Context) {
+	inFlightMutex.Lock()
+	stopping = true
+	inFlightMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		timber.Done("all in progress cache updates finished")
+	case <-ctx.Done():
+		timber.Warning("gave up waiting for in progress cache updates; cancelling them")
+		forceStop()
+		<-done
+	}
+
+	if !cluster.IsLeader() && sharedStorage() {
+		timber.Done("not flushing caches as the leader saves them to", storage)
+		return
+	}
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	for _, c := range registry {
+		c.persist()
+	}
+	timber.Done("flushed", len(registry), "caches to storage")
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mattglei.ch/lcp/internal/apis"
+	"go.mattglei.ch/lcp/internal/auth"
+	"go.mattglei.ch/timber"
+)
+
+// Status is a report of a cache's health for operators and uptime monitoring.
+type Status struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	// Updated is when the data last changed whereas LastSuccess is when it was last fetched
+	Updated             time.Time    `json:"updated"`
+	LastSuccess         *time.Time   `json:"last_success"`
+	LastError           *StatusError `json:"last_error"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	NextUpdate          *time.Time   `json:"next_update"`
+	LoadedFromStorage   bool         `json:"loaded_from_storage"`
+}
+
+type StatusError struct {
+	Message string    `json:"message"`
+	Warning bool      `json:"warning"`
+	Time    time.Time `json:"time"`
+}
+
+type health struct {
+	mutex             sync.RWMutex
+	ready             bool
+	lastSuccess       *time.Time
+	lastError         *StatusError
+	loadedFromStorage bool
+}
+
+func (h *health) succeeded() {
+	now := time.Now().UTC()
+	h.mutex.Lock()
+	h.ready = true
+	h.lastSuccess = &now
+	h.mutex.Unlock()
+}
+
+func (h *health) failed(err error) {
+	h.mutex.Lock()
+	h.lastError = &StatusError{
+		Message: err.Error(),
+		Warning: errors.Is(err, apis.ErrWarning),
+		Time:    time.Now().UTC(),
+	}
+	h.mutex.Unlock()
+}
+
+func (h *health) loaded() {
+	h.mutex.Lock()
+	h.ready = true
+	h.loadedFromStorage = true
+	h.mutex.Unlock()
+}
+
+// Status reports the health of the cache.
+func (c *Cache[T]) Status() Status {
+	c.Mutex.RLock()
+	updated := c.Updated
+	c.Mutex.RUnlock()
+
+	c.health.mutex.RLock()
+	status := Status{
+		Name:              c.name,
+		Ready:             c.health.ready,
+		Updated:           updated,
+		LastSuccess:       c.health.lastSuccess,
+		LastError:         c.health.lastError,
+		LoadedFromStorage: c.health.loadedFromStorage,
+	}
+	c.health.mutex.RUnlock()
+
+	backoff := c.Backoff()
+	status.ConsecutiveFailures = backoff.ConsecutiveFailures
+	if !backoff.NextUpdate.IsZero() {
+		status.NextUpdate = &backoff.NextUpdate
+	}
+	return status
+}
+
+// statuses returns the status of every cache, sorted by name.
+func statuses() []Status {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	var all []Status
+	for _, c := range registry {
+		all = append(all, c.Status())
+	}
+	slices.SortFunc(all, func(a, b Status) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return all
+}
+
+// ServeStatus responds with the status of every cache.
+func ServeStatus(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAuthorized(w, r, auth.ScopeStatus) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(statuses())
+	if err != nil {
+		err = fmt.Errorf("%w failed to write json status to request", err)
+		timber.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ServeHealth responds with a 200 as long as the server is able to handle requests.
+func ServeHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
+
+// ServeReady responds with a 200 once every cache has data to serve, either from storage or from
+// a successful update, and with a 503 listing the caches that don't otherwise.
+func ServeReady(w http.ResponseWriter, r *http.Request) {
+	var notReady []string
+	for _, status := range statuses() {
+		if !status.Ready {
+			notReady = append(notReady, status.Name)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	if len(notReady) != 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "waiting for data:", strings.Join(notReady, ", "))
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -1,66 +1,194 @@
 package cache
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"os"
-	"path/filepath"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/redis/go-redis/v9"
+	"go.mattglei.ch/lcp/internal/s3"
+	"go.mattglei.ch/lcp/internal/secrets"
 	"go.mattglei.ch/timber"
 )
 
-func (c *Cache[T]) persistToFile() {
-	var file *os.File
-	if _, err := os.Stat(c.filePath); os.IsNotExist(err) {
-		folder := filepath.Dir(c.filePath)
-		err := os.MkdirAll(folder, 0700)
-		if err != nil {
-			timber.Error(err, "failed to create folder at path:", folder)
-			return
-		}
-		file, err = os.Create(c.filePath)
+// ErrNotStored is returned by a Storage when nothing has been saved for a cache yet.
+var ErrNotStored = errors.New("cache has not been stored")
+
+// Storage is a backend that encoded caches are saved to so that they survive restarts.
+type Storage interface {
+	Load(ctx context.Context, name string) ([]byte, error)
+	Save(ctx context.Context, name string, data []byte) error
+	// Quarantine moves a corrupt cache out of the way so it can be inspected later without being
+	// loaded again, returning where it was moved to.
+	Quarantine(ctx context.Context, name string) (string, error)
+	String() string
+}
+
+// storage is the backend used by every cache. Caches are stored as files in the working directory
+// until SetupStorage is called.
+var storage Storage = FileStorage{}
+
+// SetupStorage selects the backend that caches are persisted to based on CACHE_STORAGE, which is
+// one of file (default), redis, or minio. It must be called before any cache is created.
+func SetupStorage(rdb *redis.Client) error {
+	switch secrets.Get().CacheStorage {
+	case "", "file":
+		storage = FileStorage{Folder: secrets.Get().CacheFolder}
+	case "redis":
+		storage = RedisStorage{Client: rdb}
+	case "minio":
+		minioClient, err := s3.NewClient()
 		if err != nil {
-			timber.Error(err, "failed to create file at path:", c.filePath)
-			return
+			return err
 		}
-	} else {
-		file, err = os.OpenFile(c.filePath, os.O_WRONLY|os.O_TRUNC, 0666)
+		minioStorage, err := NewMinioStorage(minioClient, secrets.Get().CacheBucket)
 		if err != nil {
-			timber.Error(err, "failed to read file at path:", c.filePath)
-			return
+			return err
 		}
+		storage = minioStorage
+	default:
+		return fmt.Errorf("unknown cache storage \"%s\"", secrets.Get().CacheStorage)
 	}
-	defer file.Close()
+	timber.Done("using", storage, "for cache storage")
+	return nil
+}
+
+// sharedStorage reports if every instance in a cluster saves caches to the same place, in which
+// case only the leader needs to save them.
+func sharedStorage() bool {
+	_, local := storage.(FileStorage)
+	return !local
+}
+
+// fileFormatVersion is the version of the envelope that caches are stored in. Files
+// written before the envelope was introduced have no version and are treated as version 0.
+const fileFormatVersion = 1
 
+// cacheFile is the envelope that every stored cache is wrapped in. Checksum is the hex encoded
+// SHA-256 of Payload so that a partially written or otherwise corrupted cache is detected on boot.
+type cacheFile struct {
+	Version  int             `json:"version"`
+	Checksum string          `json:"checksum"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// persistedCache is the format that a cache is stored in. History was added after the
+// initial format so files without it are still loaded.
+type persistedCache[T any] struct {
+	Data    T                  `json:"data"`
+	Updated time.Time          `json:"updated"`
+	History []CacheResponse[T] `json:"history,omitempty"`
+}
+
+// persist encodes the cache and saves it to the configured storage backend.
+func (c *Cache[T]) persist() {
 	c.Mutex.RLock()
-	bin, err := json.Marshal(CacheResponse[T]{
+	payload, err := json.Marshal(persistedCache[T]{
 		Data:    c.Data,
 		Updated: c.Updated,
+		History: c.history,
 	})
 	c.Mutex.RUnlock()
 	if err != nil {
 		timber.Error(err, "encoding data to json failed")
 		return
 	}
-	_, err = file.Write(bin)
+
+	bin, err := json.Marshal(cacheFile{
+		Version:  fileFormatVersion,
+		Checksum: checksum(payload),
+		Payload:  payload,
+	})
+	if err != nil {
+		timber.Error(err, "encoding cache file envelope to json failed")
+		return
+	}
+
+	err = storage.Save(context.Background(), c.name, bin)
 	if err != nil {
-		timber.Error(err, "writing data to json failed")
+		timber.Error(err, "saving", c.logPrefix(), "cache to", storage, "failed")
 	}
 }
 
-func (c *Cache[T]) loadFromFile() {
-	if _, err := os.Stat(c.filePath); !os.IsNotExist(err) {
-		b, err := os.ReadFile(c.filePath)
+// load restores the cache from the configured storage backend. A corrupt cache is quarantined and
+// the cache is left empty so that it is filled by the next update instead of stopping boot.
+func (c *Cache[T]) load() {
+	ctx := context.Background()
+	name := c.name
+	b, err := storage.Load(ctx, name)
+	if errors.Is(err, ErrNotStored) {
+		return
+	}
+	if err != nil {
+		timber.Error(
+			err,
+			"loading",
+			c.logPrefix(),
+			"cache from",
+			storage,
+			"failed; booting with empty cache",
+		)
+		return
+	}
+
+	data, err := decodeCacheFile[T](b)
+	if err != nil {
+		timber.Error(err, c.logPrefix(), "stored cache is corrupt; booting empty")
+		quarantined, err := storage.Quarantine(ctx, name)
 		if err != nil {
-			timber.Fatal(err, "reading from cache file from", c.filePath, "failed")
+			timber.Error(err, "failed to quarantine corrupt", c.logPrefix(), "cache")
+		} else {
+			timber.Warning(c.logPrefix(), "moved corrupt cache to", quarantined)
 		}
+		return
+	}
 
-		var data CacheResponse[T]
-		err = json.Unmarshal(b, &data)
-		if err != nil {
-			timber.Fatal(err, "unmarshaling json data from", c.filePath, "failed:", string(b))
+	c.Data = data.Data
+	c.Updated = data.Updated
+	c.history = data.History
+	c.health.loaded()
+}
+
+// decodeCacheFile verifies and parses a stored cache.
+func decodeCacheFile[T any](b []byte) (persistedCache[T], error) {
+	var (
+		data persistedCache[T]
+		file cacheFile
+	)
+	err := json.Unmarshal(b, &file)
+	if err != nil {
+		return data, fmt.Errorf("%w unmarshaling cache file envelope failed", err)
+	}
+
+	payload := []byte(file.Payload)
+	switch file.Version {
+	case 0:
+		// written before the envelope was added so the whole file is the payload
+		payload = b
+	case fileFormatVersion:
+		if sum := checksum(payload); sum != file.Checksum {
+			return data, fmt.Errorf(
+				"checksum mismatch (expected %s, got %s)",
+				file.Checksum,
+				sum,
+			)
 		}
+	default:
+		return data, fmt.Errorf("unsupported cache file version %d", file.Version)
+	}
 
-		c.Data = data.Data
-		c.Updated = data.Updated
+	err = json.Unmarshal(payload, &data)
+	if err != nil {
+		return data, fmt.Errorf("%w unmarshaling cache file payload failed", err)
 	}
+	return data, nil
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
 }
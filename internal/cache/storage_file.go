@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileStorage stores each cache as a json file in Folder.
+type FileStorage struct {
+	Folder string
+}
+
+func (s FileStorage) path(name string) string {
+	return filepath.Join(s.Folder, fmt.Sprintf("%s.json", name))
+}
+
+func (s FileStorage) Load(_ context.Context, name string) ([]byte, error) {
+	b, err := os.ReadFile(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotStored
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w reading cache file from %s failed", err, s.path(name))
+	}
+	return b, nil
+}
+
+func (s FileStorage) Save(_ context.Context, name string, data []byte) error {
+	return writeFileAtomic(s.path(name), data)
+}
+
+func (s FileStorage) Quarantine(_ context.Context, name string) (string, error) {
+	quarantined := fmt.Sprintf("%s.corrupt-%d", s.path(name), time.Now().Unix())
+	err := os.Rename(s.path(name), quarantined)
+	if err != nil {
+		return "", fmt.Errorf("%w failed to rename %s", err, s.path(name))
+	}
+	return quarantined, nil
+}
+
+func (s FileStorage) String() string {
+	return fmt.Sprintf("file storage (%s)", s.Folder)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and then renames it over path so
+// that a crash or full disk mid-write never leaves a partially written file behind.
+func writeFileAtomic(path string, data []byte) error {
+	folder := filepath.Dir(path)
+	err := os.MkdirAll(folder, 0700)
+	if err != nil {
+		return fmt.Errorf("%w failed to create folder at path %s", err, folder)
+	}
+
+	tmp, err := os.CreateTemp(folder, fmt.Sprintf(".%s.*.tmp", filepath.Base(path)))
+	if err != nil {
+		return fmt.Errorf("%w failed to create temporary file in %s", err, folder)
+	}
+	// only does anything if the rename below didn't happen
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w failed to write to temporary file", err)
+	}
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w failed to sync temporary file", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("%w failed to close temporary file", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("%w failed to rename temporary file to %s", err, path)
+	}
+
+	// sync the folder so that the rename itself survives a crash
+	dir, err := os.Open(folder)
+	if err != nil {
+		return fmt.Errorf("%w failed to open folder %s", err, folder)
+	}
+	defer dir.Close()
+	err = dir.Sync()
+	if err != nil {
+		return fmt.Errorf("%w failed to sync folder %s", err, folder)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// MinioStorage stores each cache as a json object in a MinIO bucket.
+type MinioStorage struct {
+	Client *minio.Client
+	Bucket string
+}
+
+// NewMinioStorage creates a MinioStorage, creating the bucket if it doesn't exist yet.
+func NewMinioStorage(client *minio.Client, bucket string) (MinioStorage, error) {
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return MinioStorage{}, fmt.Errorf("%w failed to check if %s bucket exists", err, bucket)
+	}
+	if !exists {
+		err = client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{})
+		if err != nil {
+			return MinioStorage{}, fmt.Errorf("%w failed to create %s bucket", err, bucket)
+		}
+	}
+	return MinioStorage{Client: client, Bucket: bucket}, nil
+}
+
+func objectName(name string) string {
+	return fmt.Sprintf("%s.json", name)
+}
+
+func (s MinioStorage) Load(ctx context.Context, name string) ([]byte, error) {
+	object, err := s.Client.GetObject(ctx, s.Bucket, objectName(name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to get %s from minio", err, objectName(name))
+	}
+	defer object.Close()
+
+	b, err := io.ReadAll(object)
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil, ErrNotStored
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to read %s from minio", err, objectName(name))
+	}
+	return b, nil
+}
+
+func (s MinioStorage) Save(ctx context.Context, name string, data []byte) error {
+	_, err := s.Client.PutObject(
+		ctx,
+		s.Bucket,
+		objectName(name),
+		bytes.NewReader(data),
+		int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/json"},
+	)
+	if err != nil {
+		return fmt.Errorf("%w failed to upload %s to minio", err, objectName(name))
+	}
+	return nil
+}
+
+func (s MinioStorage) Quarantine(ctx context.Context, name string) (string, error) {
+	quarantined := fmt.Sprintf("%s.corrupt-%d", objectName(name), time.Now().Unix())
+	_, err := s.Client.CopyObject(
+		ctx,
+		minio.CopyDestOptions{Bucket: s.Bucket, Object: quarantined},
+		minio.CopySrcOptions{Bucket: s.Bucket, Object: objectName(name)},
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w failed to copy %s in minio", err, objectName(name))
+	}
+	err = s.Client.RemoveObject(ctx, s.Bucket, objectName(name), minio.RemoveObjectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("%w failed to remove %s from minio", err, objectName(name))
+	}
+	return quarantined, nil
+}
+
+func (s MinioStorage) String() string {
+	return fmt.Sprintf("minio storage (%s bucket)", s.Bucket)
+}
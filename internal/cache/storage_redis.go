@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "lcp:cache:"
+
+// RedisStorage stores each cache as a string in Redis.
+type RedisStorage struct {
+	Client *redis.Client
+}
+
+func (s RedisStorage) Load(ctx context.Context, name string) ([]byte, error) {
+	b, err := s.Client.Get(ctx, redisKeyPrefix+name).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotStored
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to get %s from redis", err, redisKeyPrefix+name)
+	}
+	return b, nil
+}
+
+func (s RedisStorage) Save(ctx context.Context, name string, data []byte) error {
+	err := s.Client.Set(ctx, redisKeyPrefix+name, data, 0).Err()
+	if err != nil {
+		return fmt.Errorf("%w failed to set %s in redis", err, redisKeyPrefix+name)
+	}
+	return nil
+}
+
+func (s RedisStorage) Quarantine(ctx context.Context, name string) (string, error) {
+	quarantined := fmt.Sprintf("%s%s:corrupt:%d", redisKeyPrefix, name, time.Now().Unix())
+	err := s.Client.Rename(ctx, redisKeyPrefix+name, quarantined).Err()
+	if err != nil {
+		return "", fmt.Errorf("%w failed to rename %s in redis", err, redisKeyPrefix+name)
+	}
+	return quarantined, nil
+}
+
+func (s RedisStorage) String() string {
+	return "redis storage"
+}
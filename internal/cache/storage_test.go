@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type storageData struct {
+	Plays int `json:"plays"`
+}
+
+func TestDecodeCacheFile(t *testing.T) {
+	updated := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	payload := `{"data":{"plays":3},"updated":"2025-01-02T03:04:05Z"}`
+	valid := encodeCacheFile(t, fileFormatVersion, payload, checksum([]byte(payload)))
+	history := `{"data":{"plays":3},"updated":"2025-01-02T03:04:05Z",` +
+		`"history":[{"data":{"plays":2},"updated":"2025-01-01T03:04:05Z"}]}`
+
+	tests := []struct {
+		name    string
+		file    string
+		want    storageData
+		wantErr bool
+	}{
+		{name: "current version", file: valid, want: storageData{Plays: 3}},
+		{
+			// files written before the envelope was added only hold the payload
+			name: "version 0",
+			file: payload,
+			want: storageData{Plays: 3},
+		},
+		{
+			name: "history",
+			file: encodeCacheFile(t, fileFormatVersion, history, checksum([]byte(history))),
+			want: storageData{Plays: 3},
+		},
+		{name: "truncated", file: valid[:len(valid)/2], wantErr: true},
+		{
+			name:    "bad checksum",
+			file:    encodeCacheFile(t, fileFormatVersion, payload, checksum([]byte("other"))),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported version",
+			file:    encodeCacheFile(t, fileFormatVersion+1, payload, checksum([]byte(payload))),
+			wantErr: true,
+		},
+		{name: "empty", file: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := decodeCacheFile[storageData]([]byte(tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if data.Data != tt.want || !data.Updated.Equal(updated) {
+				t.Fatalf("got %+v at %s, want %+v at %s", data.Data, data.Updated, tt.want, updated)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	payload := `{"data":{"plays":3},"updated":"2025-01-02T03:04:05Z"}`
+	valid := encodeCacheFile(t, fileFormatVersion, payload, checksum([]byte(payload)))
+
+	tests := []struct {
+		name string
+		// file is the stored cache or empty if nothing is stored.
+		file string
+		want storageData
+		// wantLoaded is true if the cache must be marked as loaded from storage.
+		wantLoaded bool
+		// wantQuarantined is true if the file must be moved out of the way.
+		wantQuarantined bool
+	}{
+		{name: "nothing stored"},
+		{name: "current version", file: valid, want: storageData{Plays: 3}, wantLoaded: true},
+		{name: "version 0", file: payload, want: storageData{Plays: 3}, wantLoaded: true},
+		{name: "truncated", file: valid[:len(valid)-10], wantQuarantined: true},
+		{
+			name:            "bad checksum",
+			file:            encodeCacheFile(t, fileFormatVersion, payload, checksum(nil)),
+			wantQuarantined: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folder := t.TempDir()
+			setStorage(t, FileStorage{Folder: folder})
+			path := filepath.Join(folder, "storage.json")
+			if tt.file != "" {
+				err := os.WriteFile(path, []byte(tt.file), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := Cache[storageData]{name: "storage"}
+			c.load()
+			if c.Data != tt.want {
+				t.Errorf("got data %+v, want %+v", c.Data, tt.want)
+			}
+			if c.health.loadedFromStorage != tt.wantLoaded {
+				t.Errorf("got loaded %t, want %t", c.health.loadedFromStorage, tt.wantLoaded)
+			}
+
+			quarantined, err := filepath.Glob(path + ".corrupt-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(quarantined) == 1) != tt.wantQuarantined {
+				t.Fatalf(
+					"got quarantined files %v, want quarantined %t",
+					quarantined,
+					tt.wantQuarantined,
+				)
+			}
+			_, err = os.Stat(path)
+			if stored := err == nil; stored != (tt.file != "" && !tt.wantQuarantined) {
+				t.Fatalf("got stored file %t after load", stored)
+			}
+		})
+	}
+}
+
+// encodeCacheFile wraps payload in the envelope that caches are stored in.
+func encodeCacheFile(t *testing.T, version int, payload string, sum string) string {
+	t.Helper()
+	bin, err := json.Marshal(cacheFile{
+		Version:  version,
+		Checksum: sum,
+		Payload:  json.RawMessage(payload),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bin)
+}
+
+// setStorage replaces the storage backend until the test finishes.
+func setStorage(t *testing.T, s Storage) {
+	t.Helper()
+	previous := storage
+	storage = s
+	t.Cleanup(func() { storage = previous })
+}
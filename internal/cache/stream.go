@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mattglei.ch/lcp/internal/auth"
+	"go.mattglei.ch/timber"
+)
+
+// streamKeepAlive is how often a comment is sent to idle stream connections so that proxies
+// don't close them.
+const streamKeepAlive = 30 * time.Second
+
+// streamsClosed is done once CloseStreams is called.
+var streamsClosed, closeStreams = context.WithCancel(context.Background())
+
+// CloseStreams ends every open stream. The server waits for requests to finish when shutting down
+// without cancelling them, so it is called as shutdown starts to keep streams from holding it up.
+func CloseStreams() {
+	closeStreams()
+}
+
+// ServeStream streams the cache's data to the client using server-sent events. The current data is
+// sent as soon as the client connects and then again every time Update swaps in new data.
+func (c *Cache[T]) ServeStream(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAuthorized(w, r, c.name) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc := http.NewResponseController(w)
+
+	events := c.subscribe()
+	defer c.unsubscribe(events)
+
+	c.Mutex.RLock()
+	current := CacheResponse[T]{Data: c.Data, Updated: c.Updated}
+	c.Mutex.RUnlock()
+	err := writeEvent(w, rc, current)
+	if err != nil {
+		timber.Error(err, "failed to write initial event to", c.logPrefix(), "stream")
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-streamsClosed.Done():
+			return
+		case event := <-events:
+			err = writeEvent(w, rc, event)
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			if err == nil {
+				err = rc.Flush()
+			}
+		}
+		if err != nil {
+			// client has most likely disconnected
+			return
+		}
+	}
+}
+
+func writeEvent[T any](
+	w http.ResponseWriter,
+	rc *http.ResponseController,
+	event CacheResponse[T],
+) error {
+	bin, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%w failed to json marshal event", err)
+	}
+	_, err = fmt.Fprintf(
+		w,
+		"id: %d\nevent: update\ndata: %s\n\n",
+		event.Updated.UnixMilli(),
+		bin,
+	)
+	if err != nil {
+		return fmt.Errorf("%w failed to write event", err)
+	}
+	err = rc.Flush()
+	if err != nil {
+		return fmt.Errorf("%w failed to flush event", err)
+	}
+	return nil
+}
+
+// subscribe registers a new channel that will receive the cache's data every time it is updated.
+func (c *Cache[T]) subscribe() chan CacheResponse[T] {
+	events := make(chan CacheResponse[T], 1)
+	c.subscribersMutex.Lock()
+	if c.subscribers == nil {
+		c.subscribers = make(map[chan CacheResponse[T]]struct{})
+	}
+	c.subscribers[events] = struct{}{}
+	c.subscribersMutex.Unlock()
+	return events
+}
+
+func (c *Cache[T]) unsubscribe(events chan CacheResponse[T]) {
+	c.subscribersMutex.Lock()
+	delete(c.subscribers, events)
+	c.subscribersMutex.Unlock()
+}
+
+// broadcast sends the given event to every subscriber. Subscribers that haven't consumed their
+// previous event have it replaced so that a slow client never blocks an update.
+func (c *Cache[T]) broadcast(event CacheResponse[T]) {
+	c.subscribersMutex.Lock()
+	defer c.subscribersMutex.Unlock()
+	for events := range c.subscribers {
+		select {
+		case <-events:
+		default:
+		}
+		events <- event
+	}
+}
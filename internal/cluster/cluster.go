@@ -0,0 +1,255 @@
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/timber"
+)
+
+const (
+	logPrefix = "[cluster]"
+	leaderKey = "lcp:leader"
+	// leaseDuration is how long leadership is held without being renewed. If the leader crashes
+	// then a follower takes over after at most this long.
+	leaseDuration = 15 * time.Second
+	renewInterval = leaseDuration / 3
+)
+
+var (
+	id     string
+	rdb    *redis.Client
+	leader atomic.Bool
+)
+
+// renewScript extends the lease only if it is still held by this instance.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// resignScript deletes the lease only if it is still held by this instance.
+var resignScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Setup starts leader election when CLUSTER_ENABLED is set. Only the leader refreshes caches from
+// upstream APIs and publishes the new data to followers over Redis pub/sub. Without clustering
+// this instance is always the leader.
+func Setup(ctx context.Context, client *redis.Client) error {
+	if !secrets.Get().ClusterEnabled {
+		leader.Store(true)
+		return nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("%w failed to get hostname", err)
+	}
+	suffix := make([]byte, 4)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return fmt.Errorf("%w failed to generate instance id", err)
+	}
+	id = fmt.Sprintf("%s-%s", hostname, hex.EncodeToString(suffix))
+	rdb = client
+
+	// the first election happens before returning so that providers know if they should fetch
+	// their initial data
+	elect(ctx)
+	go func() {
+		ticker := time.NewTicker(renewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				resign()
+				return
+			case <-ticker.C:
+				elect(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+// IsLeader reports if this instance is responsible for refreshing caches.
+func IsLeader() bool {
+	return leader.Load()
+}
+
+// Enabled reports if this instance is part of a cluster.
+func Enabled() bool {
+	return rdb != nil
+}
+
+func elect(ctx context.Context) {
+	var (
+		wasLeader = leader.Load()
+		isLeader  bool
+	)
+	if wasLeader {
+		renewed, err := renewScript.Run(
+			ctx,
+			rdb,
+			[]string{leaderKey},
+			id,
+			leaseDuration.Milliseconds(),
+		).Int()
+		if err != nil {
+			timber.Error(err, logPrefix, "failed to renew leadership")
+		}
+		isLeader = renewed == 1
+	}
+	if !isLeader {
+		acquired, err := rdb.SetNX(ctx, leaderKey, id, leaseDuration).Result()
+		if err != nil {
+			timber.Error(err, logPrefix, "failed to acquire leadership")
+		}
+		isLeader = acquired
+	}
+
+	leader.Store(isLeader)
+	if isLeader && !wasLeader {
+		timber.Done(logPrefix, id, "is now the leader")
+	} else if !isLeader && wasLeader {
+		timber.Warning(logPrefix, id, "is no longer the leader")
+	}
+}
+
+// resign gives up leadership so that another instance takes over without waiting for the lease to
+// expire.
+func resign() {
+	if !leader.Swap(false) {
+		return
+	}
+	_, err := resignScript.Run(context.Background(), rdb, []string{leaderKey}, id).Result()
+	if err != nil {
+		timber.Error(err, logPrefix, "failed to resign leadership")
+		return
+	}
+	timber.Done(logPrefix, id, "resigned leadership")
+}
+
+type message struct {
+	From    string          `json:"from"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func channel(name string) string {
+	return fmt.Sprintf("lcp:cache:%s:updates", name)
+}
+
+func latestKey(name string) string {
+	return fmt.Sprintf("lcp:cache:%s:latest", name)
+}
+
+// Publish sends the payload for the named cache to every other instance in the cluster. The
+// payload is also kept as the latest for the cache so that instances that join later can load it
+// with Latest.
+func Publish(ctx context.Context, name string, payload []byte) error {
+	if !Enabled() {
+		return nil
+	}
+	bin, err := json.Marshal(message{From: id, Payload: payload})
+	if err != nil {
+		return fmt.Errorf("%w failed to encode message", err)
+	}
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, latestKey(name), payload, 0)
+		pipe.Publish(ctx, channel(name), bin)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w failed to publish to %s", err, channel(name))
+	}
+	return nil
+}
+
+// Latest returns the last payload published for the named cache, or nil if there isn't one.
+func Latest(ctx context.Context, name string) ([]byte, error) {
+	if !Enabled() {
+		return nil, nil
+	}
+	payload, err := rdb.Get(ctx, latestKey(name)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%w failed to get %s", err, latestKey(name))
+	}
+	return payload, nil
+}
+
+// Subscribe returns a channel that receives every payload published for the named cache by other
+// instances in the cluster. It returns a nil channel if clustering is disabled.
+func Subscribe(ctx context.Context, name string) (<-chan []byte, error) {
+	return subscribe(ctx, channel(name), func() bool { return true })
+}
+
+func forwardChannel(name string) string {
+	return fmt.Sprintf("lcp:cache:%s:forwarded", name)
+}
+
+// Forward sends the payload for the named cache to the leader so that work that calls upstream
+// APIs, such as handling a webhook, is only done by the leader.
+func Forward(ctx context.Context, name string, payload []byte) error {
+	bin, err := json.Marshal(message{From: id, Payload: payload})
+	if err != nil {
+		return fmt.Errorf("%w failed to encode message", err)
+	}
+	err = rdb.Publish(ctx, forwardChannel(name), bin).Err()
+	if err != nil {
+		return fmt.Errorf("%w failed to publish to %s", err, forwardChannel(name))
+	}
+	return nil
+}
+
+// Forwarded returns a channel that receives every payload forwarded for the named cache while this
+// instance is the leader. It returns a nil channel if clustering is disabled.
+func Forwarded(ctx context.Context, name string) (<-chan []byte, error) {
+	return subscribe(ctx, forwardChannel(name), IsLeader)
+}
+
+// subscribe returns a channel that receives every payload published to ch by other instances in
+// the cluster while accept returns true.
+func subscribe(ctx context.Context, ch string, accept func() bool) (<-chan []byte, error) {
+	if !Enabled() {
+		return nil, nil
+	}
+	sub := rdb.Subscribe(ctx, ch)
+	_, err := sub.Receive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to subscribe to %s", err, ch)
+	}
+
+	payloads := make(chan []byte)
+	go func() {
+		defer close(payloads)
+		for msg := range sub.Channel() {
+			var m message
+			err := json.Unmarshal([]byte(msg.Payload), &m)
+			if err != nil {
+				timber.Error(err, logPrefix, "failed to decode message from", msg.Channel)
+				continue
+			}
+			if m.From == id || !accept() {
+				continue
+			}
+			payloads <- m.Payload
+		}
+	}()
+	return payloads, nil
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/caarlos0/env/v11"
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/timber"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the behaviour of each provider that can be changed without a rebuild. Values are
+// layered so that defaults are overridden by CONFIG_FILE, which is in turn overridden by env vars.
+type Config struct {
+	AppleMusic AppleMusic `yaml:"applemusic"`
+	GitHub     GitHub     `yaml:"github"`
+	Steam      Steam      `yaml:"steam"`
+	Workouts   Workouts   `yaml:"workouts"`
+}
+
+type AppleMusic struct {
+	Interval time.Duration `yaml:"interval" env:"APPLE_MUSIC_INTERVAL"`
+	// Playlists are the ids of the library playlists that are cached, in the order they are served.
+	Playlists []string `yaml:"playlists" env:"APPLE_MUSIC_PLAYLISTS" envSeparator:","`
+}
+
+type GitHub struct {
+	Interval time.Duration `yaml:"interval" env:"GITHUB_INTERVAL"`
+}
+
+type Steam struct {
+	Interval time.Duration `yaml:"interval" env:"STEAM_INTERVAL"`
+	// Games is how many of the most recently played games are cached.
+	Games int `yaml:"games" env:"STEAM_GAMES"`
+}
+
+type Workouts struct {
+	// Limit is how many of the most recent workouts are cached.
+	Limit int `yaml:"limit" env:"WORKOUTS_LIMIT"`
+	// BodyWeightExercises are hevy exercises where the weight is assistance that is subtracted
+	// from body weight instead of added to it.
+	BodyWeightExercises []string `yaml:"body_weight_exercises" env:"HEVY_BODY_WEIGHT_EXERCISES" envSeparator:","`
+	Map                 Map      `yaml:"map"`
+}
+
+// Map is how the mapbox image for a strava activity is rendered and where it is uploaded to.
+type Map struct {
+	Style     string  `yaml:"style" env:"MAPBOX_STYLE"`
+	Width     int     `yaml:"width" env:"MAPBOX_WIDTH"`
+	Height    int     `yaml:"height" env:"MAPBOX_HEIGHT"`
+	LineWidth float64 `yaml:"line_width" env:"MAPBOX_LINE_WIDTH"`
+	LineColor string  `yaml:"line_color" env:"MAPBOX_LINE_COLOR"`
+	Bucket    string  `yaml:"bucket" env:"MAPS_BUCKET"`
+	// PublicURL is where objects in minio are served from publicly.
+	PublicURL string `yaml:"public_url" env:"MAPS_PUBLIC_URL"`
+}
+
+func defaults() Config {
+	return Config{
+		AppleMusic: AppleMusic{
+			Interval: 30 * time.Second,
+			Playlists: []string{
+				"p.AWXoZoxHLrvpJlY", // chill
+				"p.AWXoXPYSLrvpJlY", // alt
+				"p.qQXLX2rHA75zg8e", // after hours
+				"p.gek1E8efLa68Adp", // classics
+				"p.qQXLxPLtA75zg8e", // 80s
+				"p.V7VYVB0hZo53MQv", // old man
+				"p.LV0PXNoCl0EpDLW", // divorced dad
+				"p.QvDQE5RIVbAeokL", // party
+				"p.LV0PXL3Cl0EpDLW", // bops
+				"p.6xZaArOsvzb5OML", // focus
+				"p.AWXoXeAiLrvpJlY", // smooth
+				"p.O1kz7EoFVmvz704", // funk
+				"p.qQXLxPpFA75zg8e", // rap
+				"p.qQXLxpDuA75zg8e", // ROCK
+				"p.O1kz7zbsVmvz704", // country
+				"p.QvDQEN0IVbAeokL", // fall
+			},
+		},
+		GitHub: GitHub{Interval: 30 * time.Second},
+		Steam:  Steam{Interval: 15 * time.Minute, Games: 10},
+		Workouts: Workouts{
+			Limit:               20,
+			BodyWeightExercises: []string{"Chest Dip (Assisted)", "Pull Up (Assisted)"},
+			Map: Map{
+				Style:     "mattgleich/clxxsfdfm002401qj7jcxh47e",
+				Width:     462,
+				Height:    252,
+				LineWidth: 2,
+				LineColor: "000",
+				Bucket:    "mapbox-maps",
+				PublicURL: "https://s3.mattglei.ch",
+			},
+		},
+	}
+}
+
+var current atomic.Pointer[Config]
+
+func init() {
+	config := defaults()
+	current.Store(&config)
+}
+
+// Get returns the current config. The config is replaced rather than modified when it is reloaded
+// so it must not be changed by callers.
+func Get() *Config {
+	return current.Load()
+}
+
+var (
+	changed      = make(chan struct{})
+	changedMutex sync.Mutex
+)
+
+// Load reads the config, replacing the current config if it is valid.
+func Load() error {
+	config, err := read(secrets.Get().ConfigFile)
+	if err != nil {
+		return err
+	}
+	current.Store(&config)
+
+	changedMutex.Lock()
+	close(changed)
+	changed = make(chan struct{})
+	changedMutex.Unlock()
+	return nil
+}
+
+// Changed returns a channel that is closed the next time the config is loaded so that values that
+// are waited on, such as update intervals, can be applied right away.
+func Changed() <-chan struct{} {
+	changedMutex.Lock()
+	defer changedMutex.Unlock()
+	return changed
+}
+
+// read layers the config file at path and env vars over the defaults and validates the result. A
+// missing file isn't an error so that the defaults can be used without one.
+func read(path string) (Config, error) {
+	config := defaults()
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			timber.Warning("config file", path, "doesn't exist; using defaults")
+		} else if err != nil {
+			return Config{}, fmt.Errorf("%w failed to read config file %s", err, path)
+		} else {
+			err = yaml.Unmarshal(b, &config)
+			if err != nil {
+				return Config{}, fmt.Errorf("%w failed to parse config file %s", err, path)
+			}
+		}
+	}
+
+	err := env.Parse(&config)
+	if err != nil {
+		return Config{}, fmt.Errorf("%w failed to parse config env vars", err)
+	}
+
+	err = config.validate()
+	if err != nil {
+		return Config{}, fmt.Errorf("%w invalid config", err)
+	}
+	return config, nil
+}
+
+func (c Config) validate() error {
+	var errs []error
+	for name, interval := range map[string]time.Duration{
+		"applemusic.interval": c.AppleMusic.Interval,
+		"github.interval":     c.GitHub.Interval,
+		"steam.interval":      c.Steam.Interval,
+	} {
+		if interval < time.Second {
+			errs = append(errs, fmt.Errorf("%s must be at least 1s, got %s", name, interval))
+		}
+	}
+	if len(c.AppleMusic.Playlists) == 0 {
+		errs = append(errs, errors.New("applemusic.playlists must not be empty"))
+	}
+	for _, id := range c.AppleMusic.Playlists {
+		if strings.TrimSpace(id) == "" {
+			errs = append(errs, errors.New("applemusic.playlists must not contain an empty id"))
+			break
+		}
+	}
+	if c.Steam.Games < 1 {
+		errs = append(errs, fmt.Errorf("steam.games must be at least 1, got %d", c.Steam.Games))
+	}
+	if c.Workouts.Limit < 1 {
+		errs = append(
+			errs,
+			fmt.Errorf("workouts.limit must be at least 1, got %d", c.Workouts.Limit),
+		)
+	}
+
+	m := c.Workouts.Map
+	// mapbox static images are at most 1280 pixels in either dimension
+	if m.Width < 1 || m.Width > 1280 || m.Height < 1 || m.Height > 1280 {
+		errs = append(
+			errs,
+			fmt.Errorf("workouts.map size must be from 1 to 1280, got %dx%d", m.Width, m.Height),
+		)
+	}
+	if m.LineWidth <= 0 {
+		errs = append(
+			errs,
+			fmt.Errorf("workouts.map.line_width must be positive, got %f", m.LineWidth),
+		)
+	}
+	for name, value := range map[string]string{
+		"workouts.map.style":      m.Style,
+		"workouts.map.line_color": m.LineColor,
+		"workouts.map.bucket":     m.Bucket,
+		"workouts.map.public_url": m.PublicURL,
+	} {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s must not be empty", name))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/timber"
+)
+
+// Watch reloads the config whenever the process receives a SIGHUP or the config file changes until
+// ctx is cancelled. A config that fails to load is logged and the current config is kept.
+func Watch(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	var changes <-chan fsnotify.Event
+	path := secrets.Get().ConfigFile
+	if path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			timber.Error(err, "failed to create config file watcher; only reloading on SIGHUP")
+		} else {
+			defer watcher.Close()
+			// the directory is watched because editors and kubernetes config maps replace the file
+			// instead of writing to it
+			err = watcher.Add(filepath.Dir(path))
+			if err != nil {
+				timber.Error(err, "failed to watch config file; only reloading on SIGHUP")
+			} else {
+				changes = watcher.Events
+			}
+		}
+	}
+
+	// kubernetes updates config maps by swapping a symlink elsewhere in the directory, so the file
+	// that the path resolves to is compared as well as the names of changed files
+	target := resolve(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			reload("SIGHUP")
+		case event := <-changes:
+			resolved := resolve(path)
+			swapped := resolved != target
+			target = resolved
+			written := filepath.Clean(event.Name) == filepath.Clean(path) &&
+				(event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+			if !swapped && !written {
+				continue
+			}
+			reload("config file change")
+		}
+	}
+}
+
+// resolve returns the file that path points to after following symlinks, or an empty string if
+// it doesn't exist.
+func resolve(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return ""
+	}
+	return resolved
+}
+
+func reload(reason string) {
+	err := Load()
+	if err != nil {
+		timber.Error(err, "failed to reload config; keeping current config")
+		return
+	}
+	timber.Done("reloaded config on", reason)
+}
@@ -9,6 +9,9 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"go.mattglei.ch/lcp/internal/apis"
+	"go.mattglei.ch/lcp/internal/metrics"
+	"go.mattglei.ch/lcp/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type cacheEntry struct {
@@ -20,22 +23,33 @@ type cacheEntry struct {
 // BlurHash looks up or generates a BlurHash for url, caching the result in Redis and returning the
 // hash.
 func BlurHash(
+	ctx context.Context,
 	client *http.Client,
 	rdb *redis.Client,
 	url string,
 	decoder ImageDecoder,
 ) (string, error) {
-	ctx := context.Background()
+	ctx, span := tracing.Start(ctx, "images.BlurHash", attribute.String("url.full", url))
+	defer span.End()
+
 	result, err := rdb.Get(ctx, url).Result()
 	if err == redis.Nil {
-		blurhash, err := createCacheEntry(client, rdb, url, decoder, ctx)
+		metrics.ObserveBlurhashLookup(false)
+		span.SetAttributes(attribute.Bool("blurhash.cached", false))
+		blurhash, err := createCacheEntry(ctx, client, rdb, url, decoder)
 		if err != nil {
-			return "", fmt.Errorf("%w failed to generate blurhash for %s", err, url)
+			err = fmt.Errorf("%w failed to generate blurhash for %s", err, url)
+			tracing.Fail(span, err)
+			return "", err
 		}
 		return blurhash, nil
 	} else if err != nil {
-		return "", fmt.Errorf("%w failed to get %s from redis cache", err, url)
+		err = fmt.Errorf("%w failed to get %s from redis cache", err, url)
+		tracing.Fail(span, err)
+		return "", err
 	}
+	metrics.ObserveBlurhashLookup(true)
+	span.SetAttributes(attribute.Bool("blurhash.cached", true))
 
 	var cachedBlurhash *cacheEntry
 	err = json.Unmarshal([]byte(result), &cachedBlurhash)
@@ -48,13 +62,13 @@ func BlurHash(
 // createCacheEntry downloads an image, computes its BlurHash, stores it in Redis for one week, and
 // returns the hash.
 func createCacheEntry(
+	ctx context.Context,
 	client *http.Client,
 	rdb *redis.Client,
 	url string,
 	decoder ImageDecoder,
-	ctx context.Context,
 ) (string, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return "", fmt.Errorf("%w failed to create request for %s", err, url)
 	}
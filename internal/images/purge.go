@@ -0,0 +1,70 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+	"go.mattglei.ch/lcp/internal/auth"
+	"go.mattglei.ch/timber"
+)
+
+// Purge removes the cached BlurHash for url so that it is generated again the next time it is
+// looked up, returning if there was one to remove. BlurHashes are stored under their url, so the
+// key is only removed if it holds a BlurHash to keep other data in redis from being deleted.
+func Purge(ctx context.Context, rdb *redis.Client, url string) (bool, error) {
+	var removed bool
+	err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+		result, err := tx.Get(ctx, url).Result()
+		if err == redis.Nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var entry cacheEntry
+		err = json.Unmarshal([]byte(result), &entry)
+		if err != nil || entry.URL != url || entry.BlurHash == "" {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, url)
+			return nil
+		})
+		removed = err == nil
+		return err
+	}, url)
+	if err != nil {
+		return false, fmt.Errorf("%w failed to delete %s from redis cache", err, url)
+	}
+	return removed, nil
+}
+
+// PurgeRoute responds to admin requests to purge the cached BlurHash for the url query parameter.
+func PurgeRoute(rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !auth.IsAuthorized(w, r, auth.ScopeAdmin) {
+			return
+		}
+		url := r.URL.Query().Get("url")
+		if url == "" {
+			http.Error(w, "missing url query parameter", http.StatusBadRequest)
+			return
+		}
+
+		removed, err := Purge(r.Context(), rdb, url)
+		if err != nil {
+			timber.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !removed {
+			http.Error(w, fmt.Sprintf("no blurhash cached for %s", url), http.StatusNotFound)
+			return
+		}
+		timber.Info("[image cache] purged blurhash for", url)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "lcp"
+
+// Outcomes used as label values.
+const (
+	Success = "success"
+	Warning = "warning"
+	Error   = "error"
+)
+
+var (
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "Requests sent to upstream APIs by log prefix, status code, and outcome.",
+	}, []string{"prefix", "code", "outcome"})
+	upstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of requests sent to upstream APIs by log prefix.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"prefix"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Requests served from caches by cache, endpoint, and status code.",
+	}, []string{"cache", "endpoint", "code"})
+	cacheBytesServed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_bytes_served_total",
+		Help:      "Bytes of response bodies served from caches.",
+	}, []string{"cache"})
+	cacheUpdateDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cache_update_duration_seconds",
+		Help:      "Time taken to fetch new data for caches by cache and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.25, 2, 12),
+	}, []string{"cache", "outcome"})
+
+	blurhashLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blurhash_cache_lookups_total",
+		Help:      "Lookups of blurhashes in the redis cache by result (hit or miss).",
+	}, []string{"result"})
+
+	circuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upstream_circuit_open",
+		Help:      "Whether the circuit breaker for an upstream host is open (1) or closed (0).",
+	}, []string{"host"})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests rejected for exceeding a rate limit by what they were limited by.",
+	}, []string{"kind"})
+
+	minioOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "minio_operations_total",
+		Help:      "Strava map operations against minio by operation and outcome.",
+	}, []string{"operation", "outcome"})
+)
+
+// ObserveUpstreamRequest records a request sent to an upstream API. A code of 0 means that no
+// response was received.
+func ObserveUpstreamRequest(prefix string, code int, outcome string, start time.Time) {
+	codeLabel := "none"
+	if code != 0 {
+		codeLabel = strconv.Itoa(code)
+	}
+	upstreamRequests.WithLabelValues(prefix, codeLabel, outcome).Inc()
+	upstreamRequestDuration.WithLabelValues(prefix).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCircuit records the circuit breaker for host opening or closing.
+func ObserveCircuit(host string, open bool) {
+	value := 0.0
+	if open {
+		value = 1
+	}
+	circuitOpen.WithLabelValues(host).Set(value)
+}
+
+// ObserveCacheRequest records a response served from one of a cache's endpoints (data, stream,
+// history, or diff).
+func ObserveCacheRequest(cache string, endpoint string, code int, bytes int) {
+	cacheRequests.WithLabelValues(cache, endpoint, strconv.Itoa(code)).Inc()
+	cacheBytesServed.WithLabelValues(cache).Add(float64(bytes))
+}
+
+// ObserveCacheUpdate records an attempt to fetch new data for a cache.
+func ObserveCacheUpdate(cache string, outcome string, start time.Time) {
+	cacheUpdateDuration.WithLabelValues(cache, outcome).Observe(time.Since(start).Seconds())
+}
+
+// ObserveBlurhashLookup records whether a blurhash was found in the redis cache.
+func ObserveBlurhashLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	blurhashLookups.WithLabelValues(result).Inc()
+}
+
+// ObserveRateLimited records a request that was rejected for exceeding its rate limit. kind is
+// either token or ip.
+func ObserveRateLimited(kind string) {
+	rateLimited.WithLabelValues(kind).Inc()
+}
+
+// ObserveMinioOperation records an upload or removal of a strava map.
+func ObserveMinioOperation(operation string, err error) {
+	outcome := Success
+	if err != nil {
+		outcome = Error
+	}
+	minioOperations.WithLabelValues(operation, outcome).Inc()
+}
+
+// Handler exposes every metric in the prometheus text format.
+var Handler = promhttp.Handler()
@@ -0,0 +1,32 @@
+package metrics
+
+import "net/http"
+
+// Recorder captures the status code and number of bytes written for a response.
+type Recorder struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int
+}
+
+// NewRecorder wraps w, starting with a status of 200 as that is what is sent if a handler writes
+// without setting one.
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	r.Status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.Bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer so streams can be flushed.
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often buckets that have refilled are dropped from memory.
+const pruneInterval = time.Minute
+
+// MemoryLimiter keeps token buckets in memory so limits are per instance.
+type MemoryLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled completely
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]*bucket{}}
+}
+
+func (m *MemoryLimiter) Allow(
+	_ context.Context,
+	key string,
+	limit Limit,
+) (bool, time.Duration, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	m.prune(now)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	rate := limit.perMillisecond()
+	elapsed := float64(now.Sub(b.updated).Milliseconds())
+	b.tokens = min(float64(limit.Burst), b.tokens+elapsed*rate)
+	b.updated = now
+
+	allowed, wait := true, time.Duration(0)
+	if b.tokens < 1 {
+		allowed = false
+		wait = time.Duration((1-b.tokens)/rate) * time.Millisecond
+	} else {
+		b.tokens--
+	}
+	b.full = now.Add(time.Duration((float64(limit.Burst)-b.tokens)/rate) * time.Millisecond)
+	return allowed, wait, nil
+}
+
+// prune drops buckets that have refilled completely so that clients identified by IP don't grow
+// the map forever. The lock must be held.
+func (m *MemoryLimiter) prune(now time.Time) {
+	if now.Sub(m.pruned) < pruneInterval {
+		return
+	}
+	m.pruned = now
+	for key, b := range m.buckets {
+		if now.After(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func (m *MemoryLimiter) String() string {
+	return "memory"
+}
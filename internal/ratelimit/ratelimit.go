@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mattglei.ch/lcp/internal/metrics"
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/timber"
+)
+
+// Limit is a token bucket that refills at PerMinute requests per minute and holds at most Burst
+// requests.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+func (l Limit) perMillisecond() float64 {
+	return float64(l.PerMinute) / float64(time.Minute.Milliseconds())
+}
+
+// Limiter decides if a request identified by key is within limit. If it isn't, the time until the
+// next request is allowed is returned.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error)
+	String() string
+}
+
+// limiter is used for every request. Limits are kept in memory until Setup is called.
+var limiter Limiter = NewMemoryLimiter()
+
+// Setup selects where rate limits are kept based on RATE_LIMIT_STORAGE, which is either memory
+// (default) or redis. Limits kept in redis are shared between every instance.
+func Setup(rdb *redis.Client) error {
+	switch secrets.Get().RateLimitStorage {
+	case "", "memory":
+		limiter = NewMemoryLimiter()
+	case "redis":
+		limiter = RedisLimiter{Client: rdb}
+	default:
+		return fmt.Errorf("unknown rate limit storage \"%s\"", secrets.Get().RateLimitStorage)
+	}
+	timber.Done("using", limiter, "for rate limits")
+	return nil
+}
+
+// DefaultLimit is the limit for tokens that don't set their own and for requests limited by IP.
+func DefaultLimit() Limit {
+	return Limit{PerMinute: secrets.Get().RateLimit, Burst: secrets.Get().RateLimitBurst}
+}
+
+// Allow checks if the request identified by key is within limit, responding with a 429 if it
+// isn't. Limits of zero or less are unlimited. If the limiter fails then the request is allowed
+// so that an outage of redis doesn't take down every endpoint.
+func Allow(w http.ResponseWriter, r *http.Request, key string, limit Limit) bool {
+	if limit.PerMinute <= 0 {
+		return true
+	}
+	limit.Burst = max(limit.Burst, 1)
+
+	allowed, wait, err := limiter.Allow(r.Context(), key, limit)
+	if err != nil {
+		timber.Error(err, "failed to check rate limit for", key)
+		return true
+	}
+	if !allowed {
+		kind, _, _ := strings.Cut(key, ":")
+		metrics.ObserveRateLimited(kind)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+	}
+	return allowed
+}
+
+// AllowIP checks if a request that doesn't have a token is within the default limit for the
+// client's IP address, responding with a 429 if it isn't.
+func AllowIP(w http.ResponseWriter, r *http.Request) bool {
+	return Allow(w, r, "ip:"+clientIP(r), DefaultLimit())
+}
+
+// LimitByIP wraps a route that doesn't require a token so that it is limited by the client's IP
+// address instead.
+func LimitByIP(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !AllowIP(w, r) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientIP returns the address of the client, taken from X-Forwarded-For when
+// RATE_LIMIT_TRUST_PROXY is set because lcp is behind a reverse proxy. Clients can send their own
+// X-Forwarded-For, so only the entry added by the furthest of the RATE_LIMIT_TRUSTED_HOPS proxies
+// is used, counting from the right.
+func clientIP(r *http.Request) string {
+	if secrets.Get().RateLimitTrustProxy {
+		var forwarded []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			forwarded = append(forwarded, strings.Split(header, ",")...)
+		}
+		i := len(forwarded) - max(secrets.Get().RateLimitTrustedHops, 1)
+		if i >= 0 {
+			if ip := strings.TrimSpace(forwarded[i]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLimiter keeps token buckets in redis so that limits are shared between every instance.
+type RedisLimiter struct {
+	Client *redis.Client
+}
+
+// bucketScript refills and takes a token from the bucket at KEYS[1] atomically, returning if the
+// request is allowed and otherwise how many milliseconds until it would be.
+var bucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1]) or burst
+local updated = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(now - updated, 0) * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate))
+return {allowed, wait}
+`)
+
+func (l RedisLimiter) Allow(
+	ctx context.Context,
+	key string,
+	limit Limit,
+) (bool, time.Duration, error) {
+	result, err := bucketScript.Run(
+		ctx,
+		l.Client,
+		[]string{"lcp:ratelimit:" + key},
+		limit.perMillisecond(),
+		limit.Burst,
+		time.Now().UnixMilli(),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("%w failed to run rate limit script", err)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
+
+func (l RedisLimiter) String() string {
+	return "redis"
+}
@@ -0,0 +1,115 @@
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// fixture is a recorded request and the response that was received for it. Bodies that aren't
+// valid UTF-8, such as album art, are stored in BodyBase64 instead of Body.
+type fixture struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        string      `json:"body,omitempty"`
+	BodyBase64  string      `json:"body_base64,omitempty"`
+}
+
+func (f fixture) body() ([]byte, error) {
+	if f.BodyBase64 != "" {
+		return base64.StdEncoding.DecodeString(f.BodyBase64)
+	}
+	return []byte(f.Body), nil
+}
+
+func (f *fixture) setBody(body []byte) {
+	if utf8.Valid(body) {
+		f.Body = scrub(string(body))
+		return
+	}
+	f.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+}
+
+// path returns where the fixture for a request is stored. Requests are identified by their
+// scrubbed method, URL, and body so that replaying works with any credentials.
+func path(folder string, method string, u *url.URL, body string) string {
+	sum := sha256.Sum256([]byte(method + " " + scrubURL(u) + "\n" + body))
+	return filepath.Join(
+		folder,
+		u.Host,
+		fmt.Sprintf("%s-%s.json", strings.ToLower(method), hex.EncodeToString(sum[:8])),
+	)
+}
+
+// requestBody reads the body of req without consuming it. Bodies that can't be read twice, such as
+// the objects streamed to minio, are buffered and replaced with the buffered copy.
+func requestBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	if req.GetBody == nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", fmt.Errorf("%w failed to read request body", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		return scrubValue(string(b)), nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", fmt.Errorf("%w failed to get request body", err)
+	}
+	defer body.Close()
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("%w failed to read request body", err)
+	}
+	return scrubValue(string(b)), nil
+}
+
+func readFixture(path string) (fixture, error) {
+	var f fixture
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		return f, fmt.Errorf("%w failed to parse fixture %s", err, path)
+	}
+	return f, nil
+}
+
+func writeFixture(path string, f fixture) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("%w failed to create folder for fixture %s", err, path)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(f)
+	if err != nil {
+		return fmt.Errorf("%w failed to encode fixture %s", err, path)
+	}
+	err = os.WriteFile(path, buf.Bytes(), 0o644)
+	if err != nil {
+		return fmt.Errorf("%w failed to write fixture %s", err, path)
+	}
+	return nil
+}
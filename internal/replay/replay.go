@@ -0,0 +1,50 @@
+package replay
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/timber"
+)
+
+// Modes that HTTP_MODE can be set to.
+const (
+	// Live sends every request upstream. This is the default.
+	Live = "live"
+	// Record sends every request upstream and saves the responses as fixtures.
+	Record = "record"
+	// Replay serves every request from fixtures without touching the network.
+	Replay = "replay"
+)
+
+var mode = Live
+
+// Setup validates HTTP_MODE. It must be called before Transport.
+func Setup() error {
+	switch secrets.Get().HTTPMode {
+	case "", Live:
+		mode = Live
+		return nil
+	case Record:
+		timber.Warning("recording upstream responses to", secrets.Get().HTTPFixtures)
+	case Replay:
+		timber.Warning("replaying upstream responses from", secrets.Get().HTTPFixtures)
+	default:
+		return fmt.Errorf("unknown http mode \"%s\"", secrets.Get().HTTPMode)
+	}
+	mode = secrets.Get().HTTPMode
+	return nil
+}
+
+// Transport wraps base so that responses are recorded to or replayed from the fixtures folder
+// when HTTP_MODE is record or replay. In live mode base is returned as is.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	switch mode {
+	case Record:
+		return &recorder{base: base, folder: secrets.Get().HTTPFixtures}
+	case Replay:
+		return replayer{folder: secrets.Get().HTTPFixtures}
+	}
+	return base
+}
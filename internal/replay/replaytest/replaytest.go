@@ -0,0 +1,55 @@
+// Package replaytest sets up tests that replay recorded upstream responses so that providers can be
+// tested without credentials or network access.
+package replaytest
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.mattglei.ch/lcp/internal/config"
+	"go.mattglei.ch/lcp/internal/replay"
+	"go.mattglei.ch/lcp/internal/secrets"
+)
+
+// Fixtures is where the fixtures for a package's tests are stored, relative to the package.
+const Fixtures = "testdata/fixtures"
+
+// Setup loads the secrets and config for a test with env set and returns a client that serves
+// responses from the package's fixtures. Fixtures can be re-recorded by running the tests with
+// HTTP_MODE=record and real secrets set.
+func Setup(t *testing.T, env map[string]string) *http.Client {
+	t.Helper()
+	if os.Getenv("HTTP_MODE") == "" {
+		t.Setenv("HTTP_MODE", replay.Replay)
+	}
+	t.Setenv("HTTP_FIXTURES", Fixtures)
+	t.Setenv("CONFIG_FILE", "")
+	for key, value := range env {
+		if os.Getenv(key) == "" {
+			t.Setenv(key, value)
+		}
+	}
+
+	secrets.Load()
+	err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = replay.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Transport: replay.Transport(http.DefaultTransport)}
+}
+
+// Redis returns a client for an in-memory redis that is closed when the test finishes.
+func Redis(t *testing.T) *redis.Client {
+	t.Helper()
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
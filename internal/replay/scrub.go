@@ -0,0 +1,109 @@
+package replay
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.mattglei.ch/lcp/internal/secrets"
+)
+
+const redacted = "REDACTED"
+
+// secretParams are query parameters that hold credentials or account identifiers.
+var secretParams = []string{
+	"access_token",
+	"api-key",
+	"client_id",
+	"client_secret",
+	"code",
+	"key",
+	"refresh_token",
+	"steamid",
+}
+
+// secretHeaders are response headers that are never written to fixtures.
+var secretHeaders = []string{"Set-Cookie", "Authorization"}
+
+// secretFields matches json fields in bodies that hold credentials, such as the tokens returned
+// when refreshing strava's access token.
+var secretFields = regexp.MustCompile(`"(access_token|refresh_token)"\s*:\s*"[^"]*"`)
+
+// minBodySecretLength is the shortest secret that is scrubbed from response bodies. Shorter
+// secrets, such as strava's client id and steam's account id, are numbers that would also match
+// unrelated numbers and ids in the body.
+const minBodySecretLength = 16
+
+// scrub removes credential fields and every configured secret that is long enough to not be
+// mistaken for other data from a response body.
+func scrub(s string) string {
+	s = secretFields.ReplaceAllString(s, `"$1":"`+redacted+`"`)
+	for _, secret := range secretValues() {
+		if len(secret) >= minBodySecretLength {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
+
+// scrubValue removes every configured secret from s. It is used for URLs, headers, and request
+// bodies where secrets are sent as values of their own.
+func scrubValue(s string) string {
+	for _, secret := range secretValues() {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// scrubURL returns u with every credential removed. Query parameters are sorted so that the
+// result can be used to identify the request.
+func scrubURL(u *url.URL) string {
+	scrubbed := *u
+	query := scrubbed.Query()
+	for _, param := range secretParams {
+		if query.Has(param) {
+			query.Set(param, redacted)
+		}
+	}
+	scrubbed.RawQuery = query.Encode()
+	return scrubValue(scrubbed.String())
+}
+
+func scrubHeader(header http.Header) http.Header {
+	scrubbed := header.Clone()
+	for _, name := range secretHeaders {
+		scrubbed.Del(name)
+	}
+	for name, values := range scrubbed {
+		for i, value := range values {
+			scrubbed[name][i] = scrubValue(value)
+		}
+	}
+	return scrubbed
+}
+
+func secretValues() []string {
+	var values []string
+	for _, value := range []string{
+		secrets.Get().AppleMusicAppToken,
+		secrets.Get().AppleMusicUserToken,
+		secrets.Get().GitHubAccessToken,
+		secrets.Get().HevyAccessToken,
+		secrets.Get().MapboxAccessToken,
+		secrets.Get().SteamKey,
+		secrets.Get().SteamID,
+		secrets.Get().StravaClientID,
+		secrets.Get().StravaClientSecret,
+		secrets.Get().StravaOAuthCode,
+		secrets.Get().StravaAccessToken,
+		secrets.Get().StravaRefreshToken,
+		secrets.Get().MinioAccessKeyID,
+		secrets.Get().MinioSecretKey,
+	} {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,103 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"sync"
+	"unicode/utf8"
+
+	"go.mattglei.ch/timber"
+)
+
+// recorder sends requests with base and saves every response as a fixture.
+type recorder struct {
+	base   http.RoundTripper
+	folder string
+	mutex  sync.Mutex
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to read response body to record", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	f := fixture{
+		Method: req.Method,
+		URL:    scrubURL(req.URL),
+		Status: resp.StatusCode,
+		Header: scrubHeader(resp.Header),
+	}
+	// binary bodies, such as uploaded maps, are only identified by the fixture's path
+	if utf8.ValidString(reqBody) {
+		f.RequestBody = reqBody
+	}
+	f.setBody(body)
+
+	p := path(r.folder, req.Method, req.URL, reqBody)
+	r.mutex.Lock()
+	err = writeFixture(p, f)
+	r.mutex.Unlock()
+	if err != nil {
+		timber.Error(err, "failed to record response from", req.URL.Host)
+	}
+	return resp, nil
+}
+
+// replayer serves responses from fixtures saved by recorder.
+type replayer struct {
+	folder string
+}
+
+func (r replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	p := path(r.folder, req.Method, req.URL, reqBody)
+	f, err := readFixture(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf(
+			"no fixture recorded for %s %s (expected %s)",
+			req.Method,
+			scrubURL(req.URL),
+			p,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := f.body()
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to decode body of fixture %s", err, p)
+	}
+	// the body may have changed length when it was scrubbed
+	f.Header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,33 @@
+package s3
+
+import (
+	"fmt"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.mattglei.ch/lcp/internal/replay"
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/lcp/internal/tracing"
+)
+
+// NewClient creates a MinIO client for the S3 compatible object storage used for strava maps and,
+// optionally, cache storage.
+func NewClient() (*minio.Client, error) {
+	transport, err := minio.DefaultTransport(true)
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to create minio transport", err)
+	}
+	client, err := minio.New(secrets.Get().MinioEndpoint, &minio.Options{
+		Creds: credentials.NewStaticV4(
+			secrets.Get().MinioAccessKeyID,
+			secrets.Get().MinioSecretKey,
+			"",
+		),
+		Secure:    true,
+		Transport: tracing.Transport(replay.Transport(transport)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to create minio client", err)
+	}
+	return client, nil
+}
@@ -0,0 +1,145 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/caarlos0/env/v11"
+	"github.com/fsnotify/fsnotify"
+	"go.mattglei.ch/timber"
+)
+
+// fileSuffix is added to the name of an env var to read its value from the file at that path
+// instead. This is how docker and kubernetes secrets are mounted.
+const fileSuffix = "_FILE"
+
+// parse reads the secrets from env vars, replacing the value of any env var with a _FILE variant
+// with the contents of that file. The paths of the files that were read are returned.
+func parse() (Secrets, []string, error) {
+	params, err := env.GetFieldParams(&Secrets{})
+	if err != nil {
+		return Secrets{}, nil, fmt.Errorf("%w failed to get env vars for secrets", err)
+	}
+
+	var (
+		environment = env.ToMap(os.Environ())
+		files       []string
+	)
+	for _, param := range params {
+		path, ok := environment[param.Key+fileSuffix]
+		if !ok {
+			continue
+		}
+		if _, ok := environment[param.Key]; ok {
+			return Secrets{}, nil, fmt.Errorf(
+				"both %s and %s%s are set",
+				param.Key,
+				param.Key,
+				fileSuffix,
+			)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return Secrets{}, nil, fmt.Errorf("%w failed to read %s from %s", err, param.Key, path)
+		}
+		environment[param.Key] = strings.TrimSpace(string(b))
+		files = append(files, path)
+	}
+
+	secrets, err := env.ParseAsWithOptions[Secrets](env.Options{Environment: environment})
+	if err != nil {
+		return Secrets{}, nil, err
+	}
+	return secrets, files, nil
+}
+
+// Watch re-reads secrets whenever a file that a secret was read from or the tokens file changes
+// until ctx is cancelled so that rotated tokens are used without a restart. Secrets that are only
+// used during boot, such as the redis and minio credentials, still require a restart to change.
+func Watch(ctx context.Context) {
+	_, files, err := parse()
+	if err != nil {
+		return
+	}
+	if Get().TokensFile != "" {
+		files = append(files, Get().TokensFile)
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		timber.Error(err, "failed to create secret file watcher; rotated secrets require a restart")
+		return
+	}
+	defer watcher.Close()
+
+	// the directories are watched because kubernetes replaces mounted secrets by swapping a symlink
+	// rather than writing to the files themselves
+	var dirs []string
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if slices.Contains(dirs, dir) {
+			continue
+		}
+		err = watcher.Add(dir)
+		if err != nil {
+			timber.Error(err, "failed to watch", dir, "for rotated secrets")
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-watcher.Errors:
+			timber.Error(err, "watching secret files failed")
+		case event := <-watcher.Events:
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+			reload()
+		}
+	}
+}
+
+func reload() {
+	secrets, _, err := parse()
+	if err != nil {
+		timber.Error(err, "failed to reload secrets; keeping current secrets")
+		return
+	}
+	if !reflect.DeepEqual(*Get(), secrets) {
+		current.Store(&secrets)
+		timber.Done("reloaded secrets after a secret file changed")
+	}
+
+	// the tokens file is read by the auth package so it is told about every change, even if the
+	// secrets themselves are the same
+	changedMutex.Lock()
+	close(changed)
+	changed = make(chan struct{})
+	changedMutex.Unlock()
+}
+
+var (
+	changed      = make(chan struct{})
+	changedMutex sync.Mutex
+)
+
+// Changed returns a channel that is closed the next time a watched secret file or the tokens file
+// changes so that values read from them, such as api tokens, can be reloaded.
+func Changed() <-chan struct{} {
+	changedMutex.Lock()
+	defer changedMutex.Unlock()
+	return changed
+}
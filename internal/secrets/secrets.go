@@ -4,17 +4,53 @@ import (
 	"errors"
 	"io/fs"
 	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
 
-	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 	"go.mattglei.ch/timber"
 )
 
-var ENV Secrets
+var current atomic.Pointer[Secrets]
 
+func init() {
+	current.Store(&Secrets{})
+}
+
+// Get returns the current secrets. Secrets read from files are replaced when the files change (see
+// Watch) so they should be read each time they're used rather than being stored.
+func Get() *Secrets {
+	return current.Load()
+}
+
+// Secrets are read from env vars. Every field can instead be read from a file by setting the env
+// var with a _FILE suffix (e.g. GITHUB_ACCESS_TOKEN_FILE) to the file's path.
 type Secrets struct {
-	ValidTokens string `env:"VALID_TOKENS"`
-	CacheFolder string `env:"CACHE_FOLDER"`
+	ValidTokens      string `env:"VALID_TOKENS"`
+	TokensFile       string `env:"TOKENS_FILE"`
+	ConfigFile       string `env:"CONFIG_FILE" envDefault:"config.yaml"`
+	CacheStorage     string `env:"CACHE_STORAGE" envDefault:"file"`
+	CacheFolder      string `env:"CACHE_FOLDER"`
+	CacheBucket      string `env:"CACHE_BUCKET" envDefault:"lcp-cache"`
+	CacheHistorySize int    `env:"CACHE_HISTORY_SIZE" envDefault:"5"`
+
+	// Providers limits the caches that are enabled. Every cache with its secrets set is enabled
+	// if it is empty.
+	Providers []string `env:"PROVIDERS" envSeparator:","`
+
+	// public caches
+	PublicCaches       []string `env:"PUBLIC_CACHES" envSeparator:","`
+	CORSAllowedOrigins []string `env:"CORS_ALLOWED_ORIGINS" envSeparator:"," envDefault:"*"`
+
+	// rate limits
+	RateLimit           int    `env:"RATE_LIMIT" envDefault:"120"`
+	RateLimitBurst      int    `env:"RATE_LIMIT_BURST" envDefault:"30"`
+	RateLimitStorage    string `env:"RATE_LIMIT_STORAGE" envDefault:"memory"`
+	RateLimitTrustProxy bool   `env:"RATE_LIMIT_TRUST_PROXY"`
+
+	// RateLimitTrustedHops is how many reverse proxies in front of lcp append to X-Forwarded-For.
+	RateLimitTrustedHops int `env:"RATE_LIMIT_TRUSTED_HOPS" envDefault:"1"`
 
 	// strava
 	StravaClientID       string `env:"STRAVA_CLIENT_ID"`
@@ -24,22 +60,28 @@ type Secrets struct {
 	StravaRefreshToken   string `env:"STRAVA_REFRESH_TOKEN"`
 	StravaSubscriptionID int64  `env:"STRAVA_SUBSCRIPTION_ID"`
 	StravaVerifyToken    string `env:"STRAVA_VERIFY_TOKEN"`
+	StravaURL            string `env:"STRAVA_URL" envDefault:"https://www.strava.com"`
 	MapboxAccessToken    string `env:"MAPBOX_ACCESS_TOKEN"`
+	MapboxURL            string `env:"MAPBOX_URL" envDefault:"https://api.mapbox.com"`
 
 	// hevy
 	HevyAccessToken   string  `env:"HEVY_ACCESS_TOKEN"`
 	HevyBodyWeightLBS float64 `env:"HEVY_BODY_WEIGHT_LBS"`
+	HevyURL           string  `env:"HEVY_URL" envDefault:"https://api.hevyapp.com"`
 
 	// steam
 	SteamKey string `env:"STEAM_KEY"`
 	SteamID  string `env:"STEAM_ID"`
+	SteamURL string `env:"STEAM_URL" envDefault:"https://api.steampowered.com"`
 
 	// github
 	GitHubAccessToken string `env:"GITHUB_ACCESS_TOKEN"`
+	GitHubGraphQLURL  string `env:"GITHUB_GRAPHQL_URL" envDefault:"https://api.github.com/graphql"`
 
 	// apple music
 	AppleMusicAppToken  string `env:"APPLE_MUSIC_APP_TOKEN"`
 	AppleMusicUserToken string `env:"APPLE_MUSIC_USER_TOKEN"`
+	AppleMusicURL       string `env:"APPLE_MUSIC_URL" envDefault:"https://api.music.apple.com"`
 
 	// minio
 	MinioEndpoint    string `env:"MINIO_ENDPOINT"`
@@ -49,6 +91,16 @@ type Secrets struct {
 	// redis
 	RedisAddress  string `env:"REDIS_ADDRESS"`
 	RedisPassword string `env:"REDIS_PASSWORD"`
+
+	// cluster
+	ClusterEnabled bool `env:"CLUSTER_ENABLED"`
+
+	// tracing
+	OTelEndpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+
+	// recording and replaying upstream responses
+	HTTPMode     string `env:"HTTP_MODE" envDefault:"live"`
+	HTTPFixtures string `env:"HTTP_FIXTURES" envDefault:"fixtures"`
 }
 
 func Load() {
@@ -59,10 +111,44 @@ func Load() {
 		}
 	}
 
-	secrets, err := env.ParseAs[Secrets]()
+	secrets, files, err := parse()
 	if err != nil {
 		timber.Fatal(err, "parsing required env vars failed")
 	}
-	ENV = secrets
-	timber.Done("loaded secrets")
+	current.Store(&secrets)
+	if len(files) != 0 {
+		timber.Done("loaded secrets with", len(files), "read from files")
+	} else {
+		timber.Done("loaded secrets")
+	}
+}
+
+// Missing returns the env vars out of keys that aren't set, either directly or with a _FILE
+// variant. Keys that aren't fields of Secrets are looked up in the environment.
+func Missing(keys ...string) []string {
+	var (
+		secrets = reflect.ValueOf(*Get())
+		set     = map[string]bool{}
+	)
+	for i := range secrets.NumField() {
+		key, _, _ := strings.Cut(secrets.Type().Field(i).Tag.Get("env"), ",")
+		set[key] = !secrets.Field(i).IsZero()
+	}
+
+	var missing []string
+	for _, key := range keys {
+		isSet, known := set[key]
+		if !known {
+			isSet = lookupEnv(key) || lookupEnv(key+fileSuffix)
+		}
+		if !isSet {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+func lookupEnv(key string) bool {
+	value, ok := os.LookupEnv(key)
+	return ok && value != ""
 }
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentRedis records a span for every command sent by rdb as part of a traced operation.
+// Commands sent outside of a trace, such as leader lease renewals, are not recorded.
+func InstrumentRedis(rdb *redis.Client) {
+	rdb.AddHook(redisHook{})
+}
+
+type redisHook struct{}
+
+func (redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return next(ctx, cmd)
+		}
+		ctx, span := Start(
+			ctx,
+			"redis "+cmd.Name(),
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", cmd.Name()),
+		)
+		err := next(ctx, cmd)
+		endRedis(span, err)
+		return err
+	}
+}
+
+func (redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return next(ctx, cmds)
+		}
+		ctx, span := Start(
+			ctx,
+			"redis pipeline",
+			attribute.String("db.system", "redis"),
+			attribute.Int("db.redis.pipeline_length", len(cmds)),
+		)
+		err := next(ctx, cmds)
+		endRedis(span, err)
+		return err
+	}
+}
+
+// endRedis ends a redis span. A missing key is an expected result rather than a failure.
+func endRedis(span trace.Span, err error) {
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+	End(span, err)
+}
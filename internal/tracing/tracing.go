@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.mattglei.ch/lcp/internal/secrets"
+	"go.mattglei.ch/timber"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "lcp"
+
+// tracer comes from the global provider which delegates to the provider installed by Setup.
+var tracer = otel.Tracer("go.mattglei.ch/lcp")
+
+// Setup exports spans over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT is set. The exporter is
+// configured by the standard OTEL_* environment variables. Without an endpoint every span is a
+// no-op. The returned function flushes any buffered spans and must be called before exiting.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	if secrets.Get().OTelEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to create otlp trace exporter", err)
+	}
+	res, err := resource.New(
+		ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithHost(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w failed to create trace resource", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	timber.Done("exporting traces to", secrets.Get().OTelEndpoint)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span that is a child of any span in ctx.
+func Start(
+	ctx context.Context,
+	name string,
+	attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Fail marks the span as failed with err if it isn't nil.
+func Fail(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// End records err on the span, if there is one, and ends it.
+func End(span trace.Span, err error) {
+	Fail(span, err)
+	span.End()
+}
+
+// Transport wraps base so that every outgoing request is recorded as a span.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
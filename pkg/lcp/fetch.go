@@ -15,19 +15,14 @@ type Client struct {
 	httpClient http.Client
 }
 
-type Response[T CacheData] struct {
+type Response[T any] struct {
 	Data    T
 	Updated time.Time
 }
 
 func FetchCache[T CacheData](client *Client) (Response[T], error) {
-	var zeroValue Response[T] // acts as "nil" value to be used when returning an error
-	if client.Token == "" {
-		return zeroValue, errors.New("no token provided in client")
-	}
-
 	var cacheName string
-	switch any(zeroValue.Data).(type) {
+	switch any(*new(T)).(type) {
 	case AppleMusicCache:
 		cacheName = "applemusic"
 	case []GitHubRepository:
@@ -37,6 +32,16 @@ func FetchCache[T CacheData](client *Client) (Response[T], error) {
 	case []Workout:
 		cacheName = "workouts"
 	}
+	return Fetch[T](client, cacheName)
+}
+
+// Fetch fetches the cache with the given name, decoding its data into T. It is used for caches that
+// don't have a type in this package.
+func Fetch[T any](client *Client, cacheName string) (Response[T], error) {
+	var zeroValue Response[T] // acts as "nil" value to be used when returning an error
+	if client.Token == "" {
+		return zeroValue, errors.New("no token provided in client")
+	}
 
 	url, err := url.JoinPath("https://lcp.mattglei.ch", cacheName)
 	if err != nil {
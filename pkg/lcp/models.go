@@ -2,6 +2,8 @@ package lcp
 
 import "time"
 
+// CacheData is the data of the caches that FetchCache can fetch by type. Fetch can be used for any
+// other cache.
 type CacheData interface {
 	AppleMusicCache | []GitHubRepository | []SteamGame | []Workout
 }
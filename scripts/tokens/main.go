@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"go.mattglei.ch/lcp/internal/auth"
+	"go.mattglei.ch/timber"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	name := flag.String("name", "", "name of the token that is shown in request logs")
+	scopes := flag.String("scopes", "", "comma separated scopes (e.g. github,steam or *)")
+	existing := flag.String("token", "", "hash an existing lcp_ token instead of minting a new one")
+	flag.Parse()
+
+	if *name == "" {
+		timber.FatalMsg("Please provide a name for the token with -name")
+	}
+	if *scopes == "" {
+		timber.FatalMsg("Please provide the token's scopes with -scopes")
+	}
+
+	token := *existing
+	if token == "" {
+		var err error
+		token, _, err = auth.MintToken()
+		if err != nil {
+			timber.Fatal(err, "failed to mint token")
+		}
+	}
+	id, secret, ok := auth.ParseToken(token)
+	if !ok {
+		timber.FatalMsg("token must be in the form lcp_<id>_<secret>")
+	}
+	hash, err := auth.HashSecret(secret)
+	if err != nil {
+		timber.Fatal(err, "failed to hash token")
+	}
+
+	entry, err := yaml.Marshal([]auth.Token{{
+		Name:   *name,
+		ID:     id,
+		Hash:   hash,
+		Scopes: strings.Split(*scopes, ","),
+	}})
+	if err != nil {
+		timber.Fatal(err, "failed to encode tokens file entry")
+	}
+
+	timber.Info("token:", token)
+	fmt.Printf("\nadd the following to the tokens list in TOKENS_FILE:\n\n%s", entry)
+}